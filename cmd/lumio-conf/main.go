@@ -11,10 +11,7 @@ import (
 )
 
 func main() {
-	var toolMap = map[string]*toolConfig.ToolSettings{
-		"rclone": &toolConfig.RcloneSettings,
-		"s3cmd":  &toolConfig.S3cmdSettings,
-		"aws":    &toolConfig.AwsSettings}
+	toolMap := toolConfig.Tools()
 
 	var programArgs toolConfig.Settings
 	var authInfo toolConfig.AuthInfo
@@ -32,6 +29,7 @@ func main() {
 		os.Exit(1)
 	}
 	authInfo.Url = programArgs.Url
+	authInfo.Bucket = programArgs.Bucket
 
 	if programArgs.DeleteList != "" {
 		err = toolConfig.DeleteConfigSection(programArgs, toolMap)
@@ -43,6 +41,22 @@ func main() {
 			os.Exit(0)
 		}
 	}
+	if programArgs.ListEndpoints {
+		err = toolConfig.ListConfiguredEndpoints(toolMap)
+		if err != nil {
+			util.PrintErr(err, "Failed while trying to list endpoints")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if programArgs.EnvFormat != "" {
+		err = toolConfig.ExportEnv(programArgs, &authInfo)
+		if err != nil {
+			util.PrintErr(err, "Failed exporting environment variables")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	if programArgs.NonInteractive {
 		err = toolConfig.GetNonInteractiveInput(&authInfo, programArgs.ProjectId)
@@ -67,7 +81,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	for _, tool := range toolMap {
+	// Sorted so that aws profiles exist before fsspec and terraform look for them
+	for _, toolName := range util.SortedKeys(toolMap) {
+		tool := toolMap[toolName]
 		if !tool.IsEnabled {
 			if util.GlobalDebugFlag {
 				fmt.Printf("Skipping configuration for %s\n", tool.Name)
@@ -77,15 +93,14 @@ func main() {
 			if tool.ValidationDisabled {
 				fmt.Printf("%s\n\n", toolConfig.SkipValidationWarning)
 			}
-			extraInfo, err = tool.AddRemote(authInfo, tmpDir, *tool)
+			extraInfo, err = tool.Configure(authInfo, tmpDir)
 			if err != nil {
 				if !tool.IsPresent {
-					fmt.Printf("WARNING: %s command missing (if %s is a shell alias this script will not find it)\n", tool.Name, tool.Name)
+					fmt.Printf("WARNING: %s command missing (if %s is a shell alias this script will not find it)\n", tool.Command(), tool.Command())
 				}
 				util.PrintErr(err, extraInfo)
-
-				if tool.Name == "aws" && err != nil && strings.Contains(err.Error(), "argument of type 'NoneType' is not iterable") {
-					fmt.Printf("Most likely wrong credentials, check access and secret key\n")
+				if hint := tool.ExplainError(err); hint != "" {
+					fmt.Printf("%s\n", hint)
 				}
 			}
 		}
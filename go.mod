@@ -2,9 +2,11 @@ module lumioconf
 
 go 1.21
 
-require gopkg.in/ini.v1 v1.67.0
-
 require (
-	golang.org/x/sys v0.13.0 // indirect
-	golang.org/x/term v0.13.0 // indirect
+	github.com/BurntSushi/toml v1.5.0
+	golang.org/x/term v0.13.0
+	gopkg.in/ini.v1 v1.67.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.13.0 // indirect
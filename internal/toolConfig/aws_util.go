@@ -3,43 +3,16 @@ package toolConfig
 import (
 	"fmt"
 	"lumioconf/internal/util"
+	"maps"
 	"os"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"strings"
-
-	"gopkg.in/ini.v1"
 )
 
 const passedAwsRemoteValdidationMessage = `Created aws credentials config profile %s for project_%d
 	use a specific profile with the --profile flag
 `
-const lumioS3serviceConfig = `[services %s]
-s3           = 
-  endpoint_url = %s
-  multipart_chunksize = %d
-`
-
-func deleteAwsEntry(path string, sectionNames []string) error {
-	util.ReplaceInFile(path, regexp.MustCompile(`(?m)^[^\[]\s+`), "@")
-	cfg, err := ini.Load(path)
-	if err == nil {
-		for _, sectionName := range sectionNames {
-			if cfg.HasSection(sectionName) {
-				cfg.DeleteSection(sectionName)
-				err = cfg.SaveTo(path)
-				if err != nil {
-					return fmt.Errorf("Failed writing to %s, error is: %s ", path, err.Error())
-				}
-			}
-		}
-	} else {
-		return err
-	}
-	util.ReplaceInFile(path, regexp.MustCompile(`(?m)^@`), "  ")
-	return nil
-}
 
 func ValidateAwsRemote(awsCredentialFilepath string, remoteName string) error {
 	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", awsCredentialFilepath)
@@ -64,61 +37,60 @@ func getAwsConfigFilePath(pathToCredFile string) string {
 	}
 }
 
-func appendDefaultAwsEndPoint(pathToCredFile string, info AuthInfo, remoteName string) error {
-	configFilePath := getAwsConfigFilePath(pathToCredFile)
-	sectionName := fmt.Sprintf("services %s", remoteName)
-	err := deleteAwsEntry(configFilePath, []string{sectionName})
-	if err != nil {
-		return fmt.Errorf("failed while deleting aws config entry from %s, error is: %s", configFilePath, sectionName)
-	}
-	f, err := os.OpenFile(configFilePath,
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	if _, err := f.WriteString(fmt.Sprintf(lumioS3serviceConfig, remoteName, info.Url, info.Chunksize)); err != nil {
-		return err
-	}
-	return nil
-}
-
-func getAwsSetting(a AuthInfo) map[string]map[string]string {
-	awsSettings := make(map[string]map[string]string)
-	// getGenericRemoteName(a.projectId)
-	awsSettings[getGenericRemoteName(a.ProjectId)] = map[string]string{
+func getAwsSetting(a AuthInfo) map[string]map[string]any {
+	awsSettings := make(map[string]map[string]any)
+	awsSettings[getGenericRemoteName(a.ProjectId)] = map[string]any{
 		"aws_access_key_id":     a.s3AccessKey,
 		"aws_secret_access_key": a.s3SecretKey,
 		"services":              getGenericRemoteName(a.ProjectId),
-		"project_id":            fmt.Sprintf("%d", a.ProjectId)}
+		"project_id":            a.ProjectId}
 	return awsSettings
 }
 
-func addAwsEndPoint(s3auth AuthInfo, tmpDir string, awsSettings ToolSettings) (string, error) {
+// The endpoint of a profile is set in the services section of the aws config file
+func getAwsServicesSetting(a AuthInfo) map[string]map[string]any {
+	return map[string]map[string]any{
+		util.AwsSectionName("services", getGenericRemoteName(a.ProjectId)): {
+			"s3": map[string]any{
+				"endpoint_url":        a.Url,
+				"multipart_chunksize": a.Chunksize}}}
+}
+
+type awsBackend struct{}
+
+func init() {
+	registerTool(&AwsSettings, awsBackend{})
+}
+
+func (awsBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateAwsRemote(configPath, remoteName)
+}
+
+func (awsBackend) NeedsChunksize() bool {
+	return true
+}
+
+func (b awsBackend) Configure(s3auth AuthInfo, tmpDir string, awsSettings ToolSettings) (string, error) {
 	currentu, _ := user.Current()
 	awsConfigPath := strings.Replace(awsSettings.configPath, "~", currentu.HomeDir, 1)
 	tmpAwsConfig := fmt.Sprintf("%s/temp_aws.config", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
 	newConfig := getAwsSetting(s3auth)
 	if !awsSettings.NoReplace {
-		newConfig["default"] = newConfig[getGenericRemoteName(s3auth.ProjectId)]
-		newConfig["default"]["original_name"] = getGenericRemoteName(s3auth.ProjectId)
+		newConfig["default"] = maps.Clone(newConfig[remoteName])
+		newConfig["default"]["original_name"] = remoteName
 	}
-	info, err := util.UpdateConfig(newConfig, awsConfigPath, tmpAwsConfig, awsSettings.carefullUpdate, awsSettings.singleSection)
+	info, err := util.UpdateConfigFile(util.AwsConfigFormat{}, newConfig, awsConfigPath, tmpAwsConfig, awsSettings.carefullUpdate, awsSettings.singleSection)
 	if err != nil {
 		return info, err
 
 	}
-	remoteName := getGenericRemoteName(s3auth.ProjectId)
-	info, err = util.CommitTempConfigFile(getAwsConfigFilePath(awsConfigPath), getAwsConfigFilePath(tmpAwsConfig))
+	info, err = util.UpdateConfigFile(util.AwsConfigFormat{}, getAwsServicesSetting(s3auth), getAwsConfigFilePath(awsConfigPath), getAwsConfigFilePath(tmpAwsConfig), false, false)
 	if err != nil {
-		return info, err
-	}
-	err = appendDefaultAwsEndPoint(tmpAwsConfig, s3auth, remoteName)
-	if err != nil {
-		return "", fmt.Errorf("failed appending default endpoint %s to %s", tmpAwsConfig, remoteName)
+		return fmt.Sprintf("While setting default aws endpoint, %s", info), err
 	}
 
-	info, err = ValidateRemote(tmpAwsConfig, remoteName, "aws", ValidateAwsRemote, awsSettings.ValidationDisabled)
+	info, err = ValidateRemote(tmpAwsConfig, remoteName, "aws", validatorFor(b, s3auth), awsSettings.ValidationDisabled)
 	if err != nil {
 		return info, err
 	}
@@ -136,11 +108,10 @@ func addAwsEndPoint(s3auth AuthInfo, tmpDir string, awsSettings ToolSettings) (s
 	fmt.Printf("Updated aws config %s\n\n", awsConfigPath)
 	if awsSettings.NoReplace {
 		fmt.Printf("New profile not set as default, use the --profile flag to use the generated config\n")
-		cfg, _ := ini.Load(awsConfigPath)
-		default_config, err := cfg.GetSection("default")
-		if err == nil {
-			default_real_name, err := default_config.GetKey("original_name")
-			if err == nil {
+		cfg, err := util.ReadAwsConfig(awsConfigPath)
+		if err == nil && cfg.HasSection("default") {
+			default_real_name, found := cfg.Get("default", "original_name")
+			if found {
 				fmt.Printf("\tCurrent default is %s\n", default_real_name)
 
 			} else {
@@ -156,3 +127,32 @@ func addAwsEndPoint(s3auth AuthInfo, tmpDir string, awsSettings ToolSettings) (s
 	fmt.Printf(passedAwsRemoteValdidationMessage, remoteName, s3auth.ProjectId)
 	return "", nil
 }
+
+// Profiles are removed from the credentials file and their
+// endpoints from the aws config file
+func (awsBackend) Delete(configPath string, sectionNames []string) error {
+	err := util.DeleteAwsSectionsFromFile(configPath, sectionNames)
+	if err != nil {
+		return err
+	}
+	awsConfigFilePath := getAwsConfigFilePath(configPath)
+	if !util.CheckFileExists(awsConfigFilePath) {
+		return nil
+	}
+	var toDel []string
+	for _, x := range sectionNames {
+		toDel = append(toDel, util.AwsSectionName("services", x))
+	}
+	return util.DeleteSectionsFromFile(util.AwsConfigFormat{}, awsConfigFilePath, toDel)
+}
+
+func (awsBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListAwsProfiles(configPath)
+}
+
+func (awsBackend) ExplainError(err error) string {
+	if strings.Contains(err.Error(), "argument of type 'NoneType' is not iterable") {
+		return "Most likely wrong credentials, check access and secret key"
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package toolConfig
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+)
+
+const passedCyberduckValidationMessage = `Created Cyberduck bookmark %s for project_%d
+	The secret key is not stored, it is asked and kept in the keychain of the client on first use
+	duck --list s3://%s@%s/
+`
+
+const cyberduckBookmarkTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Protocol</key>
+	<string>s3</string>
+	<key>Nickname</key>
+	<string>%s</string>
+	<key>UUID</key>
+	<string>%s</string>
+	<key>Hostname</key>
+	<string>%s</string>
+	<key>Port</key>
+	<string>%s</string>
+	<key>Username</key>
+	<string>%s</string>
+	<key>Custom</key>
+	<dict>
+		<key>s3.bucket.virtualhost.disable</key>
+		<string>true</string>
+	</dict>
+</dict>
+</plist>
+`
+
+var cyberduckNicknameRegexp = regexp.MustCompile(`<key>Nickname</key>\s*<string>([^<]*)</string>`)
+
+// Cyberduck names bookmark files after their UUID, derive it from the
+// remote name so that the same file is updated and can be deleted
+func getCyberduckUUID(remoteName string) string {
+	h := sha1.Sum([]byte("lumio-conf/" + remoteName))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func getCyberduckSetting(a AuthInfo) string {
+	remoteName := getGenericRemoteName(a.ProjectId)
+	hostname := strings.TrimPrefix(strings.TrimPrefix(a.Url, "https://"), "http://")
+	port := "443"
+	if strings.HasPrefix(a.Url, "http://") {
+		port = "80"
+	}
+	return fmt.Sprintf(cyberduckBookmarkTemplate,
+		xmlEscape(remoteName),
+		getCyberduckUUID(remoteName),
+		xmlEscape(strings.TrimSuffix(hostname, "/")),
+		port,
+		xmlEscape(a.s3AccessKey))
+}
+
+type cyberduckBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&CyberduckSettings, cyberduckBackend{})
+}
+
+// The bookmark has no secret, only check that the keys work
+func (cyberduckBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return util.CheckS3Credentials(s3auth.Url, s3auth.s3AccessKey, s3auth.s3SecretKey)
+}
+
+func (b cyberduckBackend) Configure(s3auth AuthInfo, tmpDir string, cyberduckSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	bookmarkDir := strings.Replace(cyberduckSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	bookmarkPath := getEndpointFilePath(bookmarkDir, getCyberduckUUID(remoteName), ".duck")
+	tmpBookmark := fmt.Sprintf("%s/temp_bookmark.duck", tmpDir)
+
+	err := os.WriteFile(tmpBookmark, []byte(getCyberduckSetting(s3auth)), 0600)
+	if err != nil {
+		return "Failed writing temporary Cyberduck bookmark", err
+	}
+	info, err := ValidateRemote(tmpBookmark, remoteName, "cyberduck", validatorFor(b, s3auth), cyberduckSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpBookmark, bookmarkPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	hostname := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(s3auth.Url, "https://"), "http://"), "/")
+	fmt.Printf("Updated Cyberduck bookmark %s\n\n", bookmarkPath)
+	fmt.Printf(passedCyberduckValidationMessage, remoteName, s3auth.ProjectId, s3auth.s3AccessKey, hostname)
+	return "", nil
+}
+
+func (cyberduckBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		bookmarkFile := getEndpointFilePath(configDir, getCyberduckUUID(sectionName), ".duck")
+		if !util.CheckFileExists(bookmarkFile) {
+			fmt.Printf("WARNING: While deleting Cyberduck bookmark %s, no such file %s\n", sectionName, bookmarkFile)
+			continue
+		}
+		err := os.Remove(bookmarkFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted Cyberduck bookmark %s\n", bookmarkFile)
+	}
+	return nil
+}
+
+// Only bookmarks named by getCyberduckUUID are ours
+func (cyberduckBackend) ListEndpoints(configDir string) ([]string, error) {
+	bookmarks, err := listEndpointFiles(configDir, ".duck")
+	if err != nil {
+		return nil, err
+	}
+	var remoteNames []string
+	for _, bookmark := range bookmarks {
+		data, err := os.ReadFile(getEndpointFilePath(configDir, bookmark, ".duck"))
+		if err != nil {
+			return nil, err
+		}
+		match := cyberduckNicknameRegexp.FindSubmatch(data)
+		if match != nil && getCyberduckUUID(html.UnescapeString(string(match[1]))) == bookmark {
+			remoteNames = append(remoteNames, html.UnescapeString(string(match[1])))
+		}
+	}
+	return remoteNames, nil
+}
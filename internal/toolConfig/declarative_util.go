@@ -0,0 +1,382 @@
+package toolConfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tools defined by a data file, the format is described in the README
+// under Tool definitions. Files in later directories take precedence
+var toolDefinitionDirs = []string{
+	"/etc/lumio-conf/tools.d",
+	"~/.config/lumio-conf/tools.d"}
+
+type toolDefinition struct {
+	Name          string            `yaml:"name"`
+	ConfigPath    string            `yaml:"config_path"`
+	Format        string            `yaml:"format"`
+	ParentKey     string            `yaml:"parent_key"`
+	Section       string            `yaml:"section"`
+	Keys          map[string]string `yaml:"keys"`
+	Validate      []string          `yaml:"validate"`
+	ValidateEnv   map[string]string `yaml:"validate_env"`
+	Message       string            `yaml:"message"`
+	SingleSection bool              `yaml:"single_section"`
+	CarefulUpdate bool              `yaml:"careful_update"`
+}
+
+// Values available in the templates of a definition
+type toolTemplateData struct {
+	ProjectId  int
+	Url        string
+	AccessKey  string
+	SecretKey  string
+	Chunksize  int
+	Bucket     string
+	RemoteName string
+	// Only set for validate, validate_env and message
+	ConfigPath string
+}
+
+type declarativeBackend struct {
+	definition toolDefinition
+	format     util.ConfigFormat
+	// Some template uses .Chunksize
+	usesChunksize bool
+}
+
+var toolDefinitionsLoaded = false
+
+func loadToolDefinitions() {
+	if toolDefinitionsLoaded {
+		return
+	}
+	toolDefinitionsLoaded = true
+	definitions := make(map[string]declarativeBackend)
+	currentu, _ := user.Current()
+	for _, dir := range toolDefinitionDirs {
+		dir = strings.Replace(dir, "~", currentu.HomeDir, 1)
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			files, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, file := range files {
+				backend, err := readToolDefinition(file)
+				if err != nil {
+					fmt.Printf("WARNING: Ignoring tool definition %s, error is: %s\n", file, err.Error())
+					continue
+				}
+				definitions[backend.definition.Name] = backend
+			}
+		}
+	}
+	for _, name := range util.SortedKeys(definitions) {
+		if _, exists := toolRegistry[name]; exists {
+			util.PrintVerb(fmt.Sprintf("Tool definition %s ignored, a tool with the same name exists\n", name))
+			continue
+		}
+		definition := definitions[name].definition
+		var commands []string
+		if len(definition.Validate) > 0 {
+			commands = []string{definition.Validate[0]}
+		}
+		registerTool(&ToolSettings{
+			configPath:         definition.ConfigPath,
+			Name:               name,
+			IsEnabled:          false,
+			IsPresent:          false,
+			ValidationDisabled: false,
+			NoReplace:          true,
+			carefullUpdate:     definition.CarefulUpdate,
+			singleSection:      definition.SingleSection,
+			commands:           commands,
+			noCommand:          len(commands) == 0,
+		}, definitions[name])
+	}
+}
+
+func readToolDefinition(file string) (declarativeBackend, error) {
+	var definition toolDefinition
+	var backend declarativeBackend
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return backend, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&definition)
+	if err != nil {
+		return backend, err
+	}
+	if definition.Name == "" {
+		definition.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if strings.ContainsAny(definition.Name, ",: \t") {
+		return backend, errors.New(fmt.Sprintf("invalid tool name %q", definition.Name))
+	}
+	if definition.Format == "" {
+		definition.Format = "ini"
+	}
+	format, err := util.GetConfigFormat(definition.Format, definition.ParentKey)
+	if err != nil {
+		return backend, err
+	}
+	if definition.ConfigPath == "" {
+		return backend, errors.New("config_path is missing")
+	}
+	if definition.Section == "" {
+		definition.Section = "{{.RemoteName}}"
+	}
+	// Sections are deleted by name, which is only known for the remote name
+	sectionFields, err := templateFields(definition.Section)
+	if err != nil {
+		return backend, err
+	}
+	for _, field := range sectionFields {
+		if field != "RemoteName" {
+			return backend, errors.New(fmt.Sprintf("section can only use RemoteName, found %s", field))
+		}
+	}
+	if len(definition.Keys) == 0 {
+		return backend, errors.New("keys is missing")
+	}
+	// Catch template errors already when loading
+	templates := append([]string{definition.Section, definition.Message}, definition.Validate...)
+	for _, value := range definition.Keys {
+		templates = append(templates, value)
+	}
+	for _, value := range definition.ValidateEnv {
+		templates = append(templates, value)
+	}
+	usesChunksize := false
+	for _, t := range templates {
+		_, err = renderToolTemplate(t, toolTemplateData{})
+		if err != nil {
+			return backend, err
+		}
+		fields, _ := templateFields(t)
+		usesChunksize = usesChunksize || util.StringInSlice("Chunksize", fields)
+	}
+	return declarativeBackend{definition: definition, format: format, usesChunksize: usesChunksize}, nil
+}
+
+// Names of the fields of the data used in a template
+func templateFields(text string) ([]string, error) {
+	t, err := template.New("").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, child := range n.Nodes {
+					walk(child)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n != nil {
+				for _, cmd := range n.Cmds {
+					walk(cmd)
+				}
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			fields = append(fields, n.Ident[0])
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				fields = append(fields, n.Ident[1])
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walk(&n.BranchNode)
+		case *parse.RangeNode:
+			walk(&n.BranchNode)
+		case *parse.WithNode:
+			walk(&n.BranchNode)
+		case *parse.BranchNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	if t.Tree != nil {
+		walk(t.Tree.Root)
+	}
+	return fields, nil
+}
+
+func renderToolTemplate(text string, data toolTemplateData) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, data)
+	return buf.String(), err
+}
+
+func (d declarativeBackend) templateData(a AuthInfo) toolTemplateData {
+	return toolTemplateData{
+		ProjectId:  a.ProjectId,
+		Url:        a.Url,
+		AccessKey:  a.s3AccessKey,
+		SecretKey:  a.s3SecretKey,
+		Chunksize:  a.Chunksize,
+		Bucket:     getBucketName(a, d.definition.Name),
+		RemoteName: getGenericRemoteName(a.ProjectId)}
+}
+
+// Section names are derived from the remote name only, see readToolDefinition
+func (d declarativeBackend) sectionName(remoteName string) (string, error) {
+	return renderToolTemplate(d.definition.Section, toolTemplateData{RemoteName: remoteName})
+}
+
+func (d declarativeBackend) getSetting(a AuthInfo) (string, map[string]any, error) {
+	data := d.templateData(a)
+	sectionName, err := renderToolTemplate(d.definition.Section, data)
+	if err != nil {
+		return "", nil, err
+	}
+	settings := make(map[string]any)
+	for key, value := range d.definition.Keys {
+		settings[key], err = renderToolTemplate(value, data)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return sectionName, settings, nil
+}
+
+func (d declarativeBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	if len(d.definition.Validate) == 0 {
+		return nil
+	}
+	data := d.templateData(s3auth)
+	data.ConfigPath = configPath
+	var env []string
+	for _, key := range util.SortedKeys(d.definition.ValidateEnv) {
+		value, err := renderToolTemplate(d.definition.ValidateEnv[key], data)
+		if err != nil {
+			return err
+		}
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	var command []string
+	for _, arg := range d.definition.Validate {
+		arg, err := renderToolTemplate(arg, data)
+		if err != nil {
+			return err
+		}
+		command = append(command, arg)
+	}
+	return util.CheckCommandEnv(env, command[0], command[1:]...)
+}
+
+func (d declarativeBackend) Configure(s3auth AuthInfo, tmpDir string, toolSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	configPath := strings.Replace(toolSettings.configPath, "~", currentu.HomeDir, 1)
+	// Keep the file name, the validation command might depend on it
+	tmpConfig := filepath.Join(tmpDir, toolSettings.Name, filepath.Base(configPath))
+	err := os.MkdirAll(filepath.Dir(tmpConfig), 0700)
+	if err != nil {
+		return fmt.Sprintf("Failed creating temporary %s config directory", toolSettings.Name), err
+	}
+	sectionName, settings, err := d.getSetting(s3auth)
+	if err != nil {
+		return fmt.Sprintf("Failed generating %s config from the tool definition", toolSettings.Name), err
+	}
+	info, err := util.UpdateConfigFile(d.format, map[string]map[string]any{sectionName: settings}, configPath, tmpConfig, toolSettings.carefullUpdate, toolSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpConfig, sectionName, toolSettings.Name, validatorFor(d, s3auth), toolSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpConfig, configPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated %s config %s\n\n", toolSettings.Name, configPath)
+	if d.definition.Message != "" {
+		data := d.templateData(s3auth)
+		data.ConfigPath = configPath
+		message, _ := renderToolTemplate(d.definition.Message, data)
+		fmt.Printf("%s\n", strings.TrimSuffix(message, "\n"))
+	}
+	return "", nil
+}
+
+func (d declarativeBackend) Delete(configPath string, sectionNames []string) error {
+	var toDelete []string
+	for _, remoteName := range sectionNames {
+		sectionName, err := d.sectionName(remoteName)
+		if err != nil {
+			return err
+		}
+		toDelete = append(toDelete, sectionName)
+	}
+	return util.DeleteSectionsFromFile(d.format, configPath, toDelete)
+}
+
+// Section names are turned back into remote names, so that they can be given to --delete
+func (d declarativeBackend) ListEndpoints(configPath string) ([]string, error) {
+	sections, err := util.ListSections(d.format, configPath)
+	if err != nil {
+		return nil, err
+	}
+	// The section template only uses RemoteName, see readToolDefinition
+	const marker = "\x00"
+	rendered, err := d.sectionName(marker)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	for _, part := range strings.Split(rendered, marker) {
+		parts = append(parts, regexp.QuoteMeta(part))
+	}
+	sectionRegexp, err := regexp.Compile("^" + strings.Join(parts, "(.+?)") + "$")
+	if err != nil || len(parts) < 2 {
+		return nil, err
+	}
+	var remoteNames []string
+	for _, section := range sections {
+		match := sectionRegexp.FindStringSubmatch(section)
+		if match == nil {
+			continue
+		}
+		if name, err := d.sectionName(match[1]); err == nil && name == section {
+			remoteNames = append(remoteNames, match[1])
+		}
+	}
+	return remoteNames, nil
+}
+
+func (d declarativeBackend) ExplainError(err error) string {
+	return ""
+}
+
+func (d declarativeBackend) NeedsChunksize() bool {
+	return d.usesChunksize
+}
@@ -0,0 +1,104 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const passedDuckdbRemoteValidationMessage = `Created DuckDB secret %s for project_%d
+	Store it persistently by running once
+	duckdb -c ".read '%s'"
+`
+
+const duckdbSecretTemplate = `CREATE OR REPLACE PERSISTENT SECRET %s (
+    TYPE s3,
+    KEY_ID '%s',
+    SECRET '%s',
+    ENDPOINT '%s',
+    URL_STYLE 'path',
+    USE_SSL %t,
+    SCOPE 's3://'
+);`
+
+// DuckDB identifiers can not contain -
+func getDuckdbSecretName(remoteName string) string {
+	return regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(remoteName, "_")
+}
+
+// Contents of a string literal, quotes are doubled
+func escapeDuckdbString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+func getDuckdbSetting(a AuthInfo) map[string]string {
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(a.Url, "https://"), "http://")
+	remoteName := getGenericRemoteName(a.ProjectId)
+	return map[string]string{
+		remoteName: fmt.Sprintf(duckdbSecretTemplate, getDuckdbSecretName(remoteName), escapeDuckdbString(a.s3AccessKey), escapeDuckdbString(a.s3SecretKey),
+			escapeDuckdbString(strings.TrimSuffix(endpoint, "/")), !strings.HasPrefix(a.Url, "http://"))}
+}
+
+type duckdbBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&DuckdbSettings, duckdbBackend{})
+}
+
+// DuckDB can not list buckets, check the keys directly. If duckdb is installed
+// the file is also read, storing the secrets next to it instead of in ~/.duckdb
+func (duckdbBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	err := util.CheckS3Credentials(s3auth.Url, s3auth.s3AccessKey, s3auth.s3SecretKey)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("duckdb"); err != nil {
+		return nil
+	}
+	secretDir := filepath.Join(filepath.Dir(configPath), "duckdb_secrets")
+	return util.CheckCommand("duckdb", "-bail",
+		"-cmd", fmt.Sprintf("SET secret_directory='%s'", escapeDuckdbString(secretDir)),
+		"-c", fmt.Sprintf(".read '%s'", escapeDuckdbString(configPath)))
+}
+
+func (b duckdbBackend) Configure(s3auth AuthInfo, tmpDir string, duckdbSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	duckdbConfigPath := strings.Replace(duckdbSettings.configPath, "~", currentu.HomeDir, 1)
+	tmpDuckdbConfig := fmt.Sprintf("%s/temp_duckdb.sql", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	info, err := util.UpdateBlockConfig(getDuckdbSetting(s3auth), "--", duckdbConfigPath, tmpDuckdbConfig)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpDuckdbConfig, remoteName, "duckdb", validatorFor(b, s3auth), duckdbSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpDuckdbConfig, duckdbConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated DuckDB secrets %s\n\n", duckdbConfigPath)
+	fmt.Printf(passedDuckdbRemoteValidationMessage, getDuckdbSecretName(remoteName), s3auth.ProjectId, escapeDuckdbString(duckdbConfigPath))
+	return "", nil
+}
+
+func (duckdbBackend) Delete(configPath string, sectionNames []string) error {
+	err := util.DeleteConfigBlocksFromFile(configPath, "--", sectionNames)
+	if err != nil {
+		return err
+	}
+	for _, sectionName := range sectionNames {
+		fmt.Printf("Remove secrets already stored by DuckDB with\n\tduckdb -c \"DROP PERSISTENT SECRET IF EXISTS %s\"\n", getDuckdbSecretName(sectionName))
+	}
+	return nil
+}
+
+func (duckdbBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListConfigBlocks(configPath, "--")
+}
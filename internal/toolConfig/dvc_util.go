@@ -0,0 +1,160 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const passedDvcRemoteValidationMessage = `Created DVC remote %s for project_%d
+	Data is stored in %s
+	dvc push -r %s
+`
+
+// Set when parsing commandline arguments
+var dvcPrefix = ""
+
+func getDvcSectionName(remoteName string) string {
+	return fmt.Sprintf(`'remote "%s"'`, remoteName)
+}
+
+// Projects sharing a bucket keep their data under different prefixes
+func getDvcRemoteUrl(a AuthInfo) string {
+	prefix := strings.Trim(dvcPrefix, "/")
+	if prefix == "" {
+		return fmt.Sprintf("s3://%s", getBucketName(a, "dvc"))
+	}
+	return fmt.Sprintf("s3://%s/%s", getBucketName(a, "dvc"), prefix)
+}
+
+func getDvcSetting(a AuthInfo, setDefault bool) map[string]map[string]string {
+	remoteName := getGenericRemoteName(a.ProjectId)
+	dvcSettings := make(map[string]map[string]string)
+	dvcSettings[getDvcSectionName(remoteName)] = map[string]string{
+		"url":               getDvcRemoteUrl(a),
+		"endpointurl":       a.Url,
+		"access_key_id":     a.s3AccessKey,
+		"secret_access_key": a.s3SecretKey}
+	if setDefault {
+		dvcSettings["core"] = map[string]string{"remote": remoteName}
+	}
+	return dvcSettings
+}
+
+// The keys must not end up in a config file tracked by git
+func checkDvcConfigPath(configPath string) error {
+	if filepath.Base(configPath) == "config" && filepath.Base(filepath.Dir(configPath)) == ".dvc" {
+		return errors.New(fmt.Sprintf("%s is tracked by git, use %s.local for the repository instead", configPath, configPath))
+	}
+	return nil
+}
+
+// Checks that DVC accepts the config in a scratch repository, and that the keys are valid
+func ValidateDvcRemote(dvcConfigFilePath string, remoteName string, a AuthInfo) error {
+	if _, err := exec.LookPath("dvc"); err == nil {
+		scratchRepo := filepath.Join(filepath.Dir(dvcConfigFilePath), "dvc-validation")
+		err = os.MkdirAll(scratchRepo, 0700)
+		if err != nil {
+			return err
+		}
+		err = util.CheckCommand("dvc", "--cd", scratchRepo, "init", "--no-scm", "--force", "--quiet")
+		if err != nil {
+			return err
+		}
+		inf, err := util.CommitTempConfigFile(dvcConfigFilePath, filepath.Join(scratchRepo, ".dvc", "config.local"))
+		if err != nil {
+			return errors.New(inf)
+		}
+		remotes, err := util.CommandOutput("dvc", "--cd", scratchRepo, "remote", "list", "--local")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(remotes, remoteName) {
+			return errors.New(fmt.Sprintf("remote %s missing from dvc remote list", remoteName))
+		}
+	}
+	return util.CheckS3Credentials(a.Url, a.s3AccessKey, a.s3SecretKey)
+}
+
+type dvcBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&DvcSettings, dvcBackend{})
+}
+
+func (dvcBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateDvcRemote(configPath, remoteName, s3auth)
+}
+
+func (b dvcBackend) Configure(s3auth AuthInfo, tmpDir string, dvcSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	dvcConfigPath := strings.Replace(dvcSettings.configPath, "~", currentu.HomeDir, 1)
+	err := checkDvcConfigPath(dvcConfigPath)
+	if err != nil {
+		return "Refusing to store keys in the DVC repository config", err
+	}
+	tmpDvcConfig := fmt.Sprintf("%s/temp_dvc.config", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	newConfig := getDvcSetting(s3auth, !dvcSettings.NoReplace)
+	info, err := util.UpdateConfig(newConfig, dvcConfigPath, tmpDvcConfig, dvcSettings.carefullUpdate, dvcSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpDvcConfig, remoteName, "dvc", validatorFor(b, s3auth), dvcSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpDvcConfig, dvcConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated DVC config %s\n\n", dvcConfigPath)
+	if !dvcSettings.NoReplace {
+		fmt.Printf("New remote set as default\n")
+	}
+	fmt.Printf(passedDvcRemoteValidationMessage, remoteName, s3auth.ProjectId, newConfig[getDvcSectionName(remoteName)]["url"], remoteName)
+	return "", nil
+}
+
+func (dvcBackend) Delete(configPath string, sectionNames []string) error {
+	var toDelete []string
+	for _, sectionName := range sectionNames {
+		toDelete = append(toDelete, getDvcSectionName(sectionName))
+	}
+	err := util.DeleteIniSectionsFromFile(configPath, toDelete)
+	if err != nil {
+		return err
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.HasSection("core") && util.StringInSlice(cfg.Section("core").Key("remote").String(), sectionNames) {
+		cfg.Section("core").DeleteKey("remote")
+		fmt.Print("WARNING: Also removed the default DVC remote\n")
+		return cfg.SaveTo(configPath)
+	}
+	return nil
+}
+
+func (dvcBackend) ListEndpoints(configPath string) ([]string, error) {
+	sections, err := util.ListIniSections(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var remoteNames []string
+	for _, section := range sections {
+		if remoteName, found := strings.CutPrefix(section, `'remote "`); found {
+			remoteNames = append(remoteNames, strings.TrimSuffix(remoteName, `"'`))
+		}
+	}
+	return remoteNames, nil
+}
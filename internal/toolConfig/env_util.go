@@ -0,0 +1,206 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Statement setting a single variable in each supported format,
+// and how a value is quoted for it
+type envFormat struct {
+	statement string
+	quote     func(string) string
+}
+
+var envFormats = map[string]envFormat{
+	"sh":     {"export %s=%s", quoteSh},
+	"fish":   {"set -gx %s %s", quoteFish},
+	"csh":    {"setenv %s %s", quoteCsh},
+	"dotenv": {"%s=%s", quoteDotenv}}
+
+// Nothing is special inside single quotes, a quote ends them
+func quoteSh(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fish allows escaping quotes and backslashes inside single quotes
+func quoteFish(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// As in sh, but history substitution with ! also happens inside single quotes
+func quoteCsh(value string) string {
+	return "'" + strings.NewReplacer("'", `'\''`, "!", `\!`).Replace(value) + "'"
+}
+
+var dotenvPlainValue = regexp.MustCompile(`^[A-Za-z0-9_./:@+=-]*$`)
+
+// Plain values are not quoted, others are single quoted so that no variables are expanded
+func quoteDotenv(value string) string {
+	if dotenvPlainValue.MatchString(value) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// Where the keys for a project are looked for when not given,
+// the section name and the keys holding the access key, secret key and endpoint
+type envKeySource struct {
+	settings    *ToolSettings
+	section     func(int) string
+	accessKey   string
+	secretKey   string
+	endpointKey string
+	// Values of the keys in the section, false if there is no such section
+	read func(configPath string, sectionName string, keys ...string) ([]string, bool)
+}
+
+var envKeySources = []envKeySource{
+	{&RcloneSettings, getPrivateRcloneRemoteName, "access_key_id", "secret_access_key", "endpoint", readIniKeys},
+	{&AwsSettings, getGenericRemoteName, "aws_access_key_id", "aws_secret_access_key", "endpoint_url", readAwsProfileKeys},
+	{&S3cmdSettings, getGenericRemoteName, "access_key", "secret_key", "host_base", readIniKeys}}
+
+func readIniKeys(configPath string, sectionName string, keys ...string) ([]string, bool) {
+	cfg, err := ini.Load(configPath)
+	if err != nil || !cfg.HasSection(sectionName) {
+		return nil, false
+	}
+	var values []string
+	for _, key := range keys {
+		values = append(values, cfg.Section(sectionName).Key(key).String())
+	}
+	return values, true
+}
+
+// An endpoint_url missing from the profile is looked up in
+// the services section of the profile in the aws config file
+func readAwsProfileKeys(configPath string, sectionName string, keys ...string) ([]string, bool) {
+	cfg, err := util.ReadAwsConfig(configPath)
+	if err != nil || !cfg.HasSection(sectionName) {
+		return nil, false
+	}
+	services, hasServices := cfg.Get(sectionName, "services")
+	var values []string
+	for _, key := range keys {
+		value, _ := cfg.Get(sectionName, key)
+		if key == "endpoint_url" && value == "" && hasServices {
+			awsConfig, err := util.ReadAwsConfig(getAwsConfigFilePath(configPath))
+			if err == nil {
+				value, _ = awsConfig.GetNested(util.AwsSectionName("services", services), "s3", "endpoint_url")
+			}
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+// rclone reads a remote from RCLONE_CONFIG_<REMOTE>_<OPTION>
+func getRcloneEnvName(remoteName string, option string) string {
+	return strings.ToUpper(strings.ReplaceAll(fmt.Sprintf("RCLONE_CONFIG_%s_%s", remoteName, option), "-", "_"))
+}
+
+func getEnvSetting(a AuthInfo) map[string]string {
+	envSettings := map[string]string{
+		"AWS_ACCESS_KEY_ID":     a.s3AccessKey,
+		"AWS_SECRET_ACCESS_KEY": a.s3SecretKey,
+		"AWS_ENDPOINT_URL":      a.Url,
+		"S3_ENDPOINT":           a.Url,
+		"LUMI_PROJECT_ID":       fmt.Sprintf("%d", a.ProjectId)}
+	for remoteName, options := range getRcloneSetting(a) {
+		for option, value := range options {
+			envSettings[getRcloneEnvName(remoteName, option)] = value
+		}
+	}
+	return envSettings
+}
+
+// Use the keys from the first tool config which has the project
+func readKeysFromConfigs(a *AuthInfo) (string, error) {
+	currentu, _ := user.Current()
+	for _, source := range envKeySources {
+		configPath := strings.Replace(source.settings.configPath, "~", currentu.HomeDir, 1)
+		if !util.CheckFileExists(configPath) {
+			continue
+		}
+		values, found := source.read(configPath, source.section(a.ProjectId), source.accessKey, source.secretKey, source.endpointKey)
+		if !found || values[0] == "" || values[1] == "" {
+			continue
+		}
+		a.s3AccessKey = values[0]
+		a.s3SecretKey = values[1]
+		if values[2] != "" {
+			a.Url = values[2]
+		}
+		return configPath, nil
+	}
+	return "", errors.New(fmt.Sprintf("no keys for project_%d found in the rclone, aws or s3cmd configs, set LUMIO_S3_ACCESS and LUMIO_S3_SECRET", a.ProjectId))
+}
+
+// Prints the variables for a project, or writes them to programArgs.EnvFile
+// Keys given with LUMIO_S3_ACCESS and LUMIO_S3_SECRET are used over the ones in existing configs
+func ExportEnv(programArgs Settings, a *AuthInfo) error {
+	format, found := envFormats[programArgs.EnvFormat]
+	if !found {
+		return errors.New(fmt.Sprintf("Unknown format %s for --env. Valid options are: %s", programArgs.EnvFormat, strings.Join(util.SortedKeys(envFormats), " ")))
+	}
+	var err error
+	a.ProjectId = programArgs.ProjectId
+	if projectIdEnvVal, projectIdEnvValIsPresent := os.LookupEnv("LUMIO_PROJECTID"); a.ProjectId == 0 && projectIdEnvValIsPresent {
+		a.ProjectId, err = strconv.Atoi(projectIdEnvVal)
+		if err != nil {
+			return errors.New("Value for LUMIO_PROJECTID needs to be a number")
+		}
+	}
+	if a.ProjectId == 0 {
+		return errors.New("--env needs either the --project-number flag or the LUMIO_PROJECTID environment variable")
+	}
+	err = validateProjId(a.ProjectId)
+	if err != nil {
+		return err
+	}
+	s3AccessKeyEnvVal, s3AccessKeyIsPresent := os.LookupEnv("LUMIO_S3_ACCESS")
+	s3SecretKeyEnvVal, s3SecretKeyIsPresent := os.LookupEnv("LUMIO_S3_SECRET")
+	if s3AccessKeyIsPresent && s3SecretKeyIsPresent {
+		a.s3AccessKey = s3AccessKeyEnvVal
+		a.s3SecretKey = s3SecretKeyEnvVal
+	} else {
+		configPath, err := readKeysFromConfigs(a)
+		if err != nil {
+			return err
+		}
+		// stdout might be evaluated by the shell
+		fmt.Fprintf(os.Stderr, "Using the keys for project_%d from %s\n", a.ProjectId, configPath)
+	}
+
+	envSettings := getEnvSetting(*a)
+	lines := []string{fmt.Sprintf("# LUMI-O credentials for project_%d, generated by lumio-conf", a.ProjectId)}
+	for _, k := range util.SortedKeys(envSettings) {
+		lines = append(lines, fmt.Sprintf(format.statement, k, format.quote(envSettings[k])))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	if programArgs.EnvFile == "" {
+		fmt.Print(content)
+		return nil
+	}
+	currentu, _ := user.Current()
+	envFilePath := strings.Replace(programArgs.EnvFile, "~", currentu.HomeDir, 1)
+	err = os.WriteFile(envFilePath, []byte(content), 0600)
+	if err != nil {
+		return err
+	}
+	// An existing file keeps its permissions when overwritten
+	err = os.Chmod(envFilePath, 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s environment for project_%d to %s\n", programArgs.EnvFormat, a.ProjectId, envFilePath)
+	return nil
+}
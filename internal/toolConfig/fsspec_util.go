@@ -0,0 +1,159 @@
+package toolConfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedFsspecRemoteValidationMessage = `Created fsspec s3 configuration for project_%d using %s
+	s3:// urls opened with fsspec, e.g by pandas, xarray or zarr, now use LUMI-O
+	Only one project can be configured for fsspec at a time
+`
+
+const fsspecPlaintextKeysWarning = `WARNING: No aws profile %s found in the default aws credentials file, the access and secret key are written in plaintext to %s
+	Configure aws as well, e.g with --configure-only aws,fsspec, to reference the aws profile instead
+`
+
+// fsspec merges all json files in its config directory by protocol,
+// the remote name is kept under a key which is never used as a protocol
+const fsspecMarkerKey = "lumio-conf"
+
+// Printed by the probe when s3fs can not be imported
+const fsspecProbeSkipped = "lumio-conf: s3fs not available"
+
+const fsspecProbe = `import sys
+try:
+    import fsspec
+    import s3fs
+except ImportError:
+    print("` + fsspecProbeSkipped + `")
+    sys.exit(0)
+fsspec.filesystem("s3").ls("")
+`
+
+func getFsspecSetting(a AuthInfo) map[string]map[string]any {
+	remoteName := getGenericRemoteName(a.ProjectId)
+	s3Settings := map[string]any{
+		"client_kwargs": map[string]any{"endpoint_url": a.Url}}
+	// A profile outside of the default location would not be found by botocore
+	awsCredentialsPath, hasProfile := getExistingAwsProfile(remoteName)
+	currentu, _ := user.Current()
+	if hasProfile && awsCredentialsPath == strings.Replace(systemDefaultConfigPaths["aws"], "~", currentu.HomeDir, 1) {
+		s3Settings["profile"] = remoteName
+	} else {
+		s3Settings["key"] = a.s3AccessKey
+		s3Settings["secret"] = a.s3SecretKey
+	}
+	return map[string]map[string]any{
+		"s3":            s3Settings,
+		fsspecMarkerKey: {"s3": remoteName}}
+}
+
+// Validation is skipped if python or s3fs are not available
+func ValidateFsspecRemote(fsspecConfigFilePath string, remoteName string) error {
+	if _, err := exec.LookPath("python3"); err != nil {
+		fmt.Printf("python3 not found, skipping validation of the fsspec configuration\n")
+		return nil
+	}
+	env := []string{fmt.Sprintf("FSSPEC_CONFIG_DIR=%s", filepath.Dir(fsspecConfigFilePath))}
+	out, err := util.CommandOutputEnv(env, "python3", "-c", fsspecProbe)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(out, fsspecProbeSkipped) {
+		fmt.Printf("s3fs not importable by python3, skipping validation of the fsspec configuration\n")
+	}
+	return nil
+}
+
+type fsspecBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&FsspecSettings, fsspecBackend{})
+}
+
+func (fsspecBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateFsspecRemote(configPath, remoteName)
+}
+
+func (b fsspecBackend) Configure(s3auth AuthInfo, tmpDir string, fsspecSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	fsspecConfigPath := strings.Replace(fsspecSettings.configPath, "~", currentu.HomeDir, 1)
+	// fsspec reads every json file in the directory
+	tmpFsspecConfig := fmt.Sprintf("%s/fsspec/s3.json", tmpDir)
+	err := os.MkdirAll(filepath.Dir(tmpFsspecConfig), 0700)
+	if err != nil {
+		return "Failed creating temporary fsspec config directory", err
+	}
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	newConfig := getFsspecSetting(s3auth)
+	info, err := util.UpdateJsonConfig(newConfig, "", fsspecConfigPath, tmpFsspecConfig, fsspecSettings.carefullUpdate, fsspecSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpFsspecConfig, remoteName, "fsspec", validatorFor(b, s3auth), fsspecSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpFsspecConfig, fsspecConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	credentials := "the keys stored in the fsspec config"
+	if profile, found := newConfig["s3"]["profile"]; found {
+		credentials = fmt.Sprintf("the aws profile %s", profile)
+	} else {
+		fmt.Printf(fsspecPlaintextKeysWarning, remoteName, fsspecConfigPath)
+	}
+	fmt.Printf("Updated fsspec config %s\n\n", fsspecConfigPath)
+	fmt.Printf(passedFsspecRemoteValidationMessage, s3auth.ProjectId, credentials)
+	return "", nil
+}
+
+// The s3 options are only removed if they were created for one of the endpoints
+// Remote the s3 defaults were written for, empty if not written by us
+func getFsspecConfiguredRemote(configPath string) (string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	var content map[string]any
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed parsing %s as json, error is: %s", configPath, err.Error()))
+	}
+	marker, _ := content[fsspecMarkerKey].(map[string]any)
+	configuredRemote, _ := marker["s3"].(string)
+	return configuredRemote, nil
+}
+
+func (fsspecBackend) Delete(configPath string, sectionNames []string) error {
+	configuredRemote, err := getFsspecConfiguredRemote(configPath)
+	if err != nil {
+		return err
+	}
+	if !util.StringInSlice(configuredRemote, sectionNames) {
+		fmt.Printf("WARNING: fsspec config %s is not configured for any of %s\n", configPath, strings.Join(sectionNames, " "))
+		return nil
+	}
+	return util.DeleteJsonSectionsFromFile(configPath, "", []string{"s3", fsspecMarkerKey})
+}
+
+func (fsspecBackend) ListEndpoints(configPath string) ([]string, error) {
+	if !util.CheckFileExists(configPath) {
+		return nil, nil
+	}
+	configuredRemote, err := getFsspecConfiguredRemote(configPath)
+	if err != nil || configuredRemote == "" {
+		return nil, err
+	}
+	return []string{configuredRemote}, nil
+}
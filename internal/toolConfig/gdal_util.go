@@ -0,0 +1,190 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os/exec"
+	"os/user"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const passedGdalRemoteValidationMessage = `Created GDAL configuration for project_%d using %s
+	Files can be opened with /vsis3/<bucket_name>/<object>
+	Only one project can be configured for GDAL at a time
+`
+
+const gdalConfigSection = "configoptions"
+
+// Keys which are only valid when using an aws profile or inline credentials
+var gdalProfileKeys = []string{"AWS_PROFILE", "CPL_AWS_CREDENTIALS_FILE", "AWS_CONFIG_FILE"}
+var gdalInlineKeys = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+
+// Errors from gdalinfo which mean the credentials or endpoint are wrong
+// Printed for the root of /vsis3/ when the credentials are accepted
+var gdalNotAFileErrors = []string{"not recognized as a supported file format", "not recognized as being in a supported file format"}
+
+var gdalS3Errors = []string{"InvalidAccessKeyId", "SignatureDoesNotMatch", "AccessDenied", "HTTP response code: 403", "Couldn't resolve host", "Could not resolve host"}
+
+// Returns the aws credentials file if a profile has been created for the project
+func getExistingAwsProfile(remoteName string) (string, bool) {
+	currentu, _ := user.Current()
+	awsCredentialsPath := strings.Replace(AwsSettings.configPath, "~", currentu.HomeDir, 1)
+	if !util.CheckFileExists(awsCredentialsPath) {
+		return "", false
+	}
+	cfg, err := util.ReadAwsConfig(awsCredentialsPath)
+	if err != nil || !cfg.HasSection(remoteName) {
+		return "", false
+	}
+	return awsCredentialsPath, true
+}
+
+func getGdalSetting(a AuthInfo) (map[string]map[string]string, []string) {
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(a.Url, "https://"), "http://")
+	remoteName := getGenericRemoteName(a.ProjectId)
+	useHttps := "YES"
+	if strings.HasPrefix(a.Url, "http://") {
+		useHttps = "NO"
+	}
+	gdalSettings := map[string]string{
+		"AWS_S3_ENDPOINT":     strings.TrimSuffix(endpoint, "/"),
+		"AWS_VIRTUAL_HOSTING": "FALSE",
+		"AWS_HTTPS":           useHttps,
+		"LUMIO_REMOTE_NAME":   remoteName}
+	staleKeys := gdalProfileKeys
+	awsCredentialsPath, hasProfile := getExistingAwsProfile(remoteName)
+	if hasProfile {
+		gdalSettings["AWS_PROFILE"] = remoteName
+		gdalSettings["CPL_AWS_CREDENTIALS_FILE"] = awsCredentialsPath
+		gdalSettings["AWS_CONFIG_FILE"] = getAwsConfigFilePath(awsCredentialsPath)
+		staleKeys = gdalInlineKeys
+	} else {
+		gdalSettings["AWS_ACCESS_KEY_ID"] = a.s3AccessKey
+		gdalSettings["AWS_SECRET_ACCESS_KEY"] = a.s3SecretKey
+	}
+	return map[string]map[string]string{gdalConfigSection: gdalSettings}, staleKeys
+}
+
+func deleteIniKeys(path string, sectionName string, keys []string) error {
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return err
+	}
+	if !cfg.HasSection(sectionName) {
+		return nil
+	}
+	for _, key := range keys {
+		cfg.Section(sectionName).DeleteKey(key)
+	}
+	return cfg.SaveTo(path)
+}
+
+// gdalinfo can not list a directory, but the credentials are checked when
+// trying to open the root of /vsis3/
+func ValidateGdalRemote(gdalConfigFilePath string, remoteName string) error {
+	if _, err := exec.LookPath("gdalinfo"); err != nil {
+		fmt.Printf("gdalinfo not found, skipping validation of the GDAL configuration\n")
+		return nil
+	}
+	err := util.CheckCommandEnv([]string{"GDAL_CONFIG_FILE=" + gdalConfigFilePath}, "gdalinfo", "/vsis3/")
+	if err == nil {
+		return nil
+	}
+	for _, s3Error := range gdalS3Errors {
+		if strings.Contains(err.Error(), s3Error) {
+			return err
+		}
+	}
+	for _, notAFileError := range gdalNotAFileErrors {
+		if strings.Contains(err.Error(), notAFileError) {
+			return nil
+		}
+	}
+	return err
+}
+
+type gdalBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&GdalSettings, gdalBackend{})
+}
+
+func (gdalBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateGdalRemote(configPath, remoteName)
+}
+
+func (b gdalBackend) Configure(s3auth AuthInfo, tmpDir string, gdalSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	gdalConfigPath := strings.Replace(gdalSettings.configPath, "~", currentu.HomeDir, 1)
+	tmpGdalConfig := fmt.Sprintf("%s/temp_gdalrc", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	newConfig, staleKeys := getGdalSetting(s3auth)
+	info, err := util.UpdateConfig(newConfig, gdalConfigPath, tmpGdalConfig, gdalSettings.carefullUpdate, gdalSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	// Keep other config options, but not credentials from a previous configuration
+	err = deleteIniKeys(tmpGdalConfig, gdalConfigSection, staleKeys)
+	if err != nil {
+		return "Failed removing old credentials from GDAL config", err
+	}
+	info, err = ValidateRemote(tmpGdalConfig, remoteName, "gdal", validatorFor(b, s3auth), gdalSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpGdalConfig, gdalConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	credentials := "the keys stored in the GDAL config"
+	if profile, found := newConfig[gdalConfigSection]["AWS_PROFILE"]; found {
+		credentials = fmt.Sprintf("the aws profile %s", profile)
+	}
+	fmt.Printf("Updated GDAL config %s\n\n", gdalConfigPath)
+	fmt.Printf(passedGdalRemoteValidationMessage, s3auth.ProjectId, credentials)
+	return "", nil
+}
+
+// The GDAL config can only hold one project, the options are removed
+// if they were created for one of the endpoints
+func (gdalBackend) Delete(configPath string, sectionNames []string) error {
+	if !util.CheckFileExists(configPath) {
+		return errors.New(fmt.Sprintf("no such file %s", configPath))
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return err
+	}
+	configuredRemote := cfg.Section(gdalConfigSection).Key("LUMIO_REMOTE_NAME").String()
+	if !util.StringInSlice(configuredRemote, sectionNames) {
+		fmt.Printf("WARNING: GDAL config %s is not configured for any of %s\n", configPath, strings.Join(sectionNames, " "))
+		return nil
+	}
+	keys := append([]string{"AWS_S3_ENDPOINT", "AWS_VIRTUAL_HOSTING", "AWS_HTTPS", "LUMIO_REMOTE_NAME"}, gdalProfileKeys...)
+	err = deleteIniKeys(configPath, gdalConfigSection, append(keys, gdalInlineKeys...))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted GDAL options for %s in file %s\n", configuredRemote, configPath)
+	return nil
+}
+
+// The config holds options for a single endpoint
+func (gdalBackend) ListEndpoints(configPath string) ([]string, error) {
+	if !util.CheckFileExists(configPath) {
+		return nil, nil
+	}
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	configuredRemote := cfg.Section(gdalConfigSection).Key("LUMIO_REMOTE_NAME").String()
+	if configuredRemote == "" {
+		return nil, nil
+	}
+	return []string{configuredRemote}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -30,6 +31,38 @@ func getGenericRemoteName(projid int) string {
 
 }
 
+// Bucket for tools which need one, unless given with --bucket
+// a separate bucket is used for each tool
+func getBucketName(a AuthInfo, toolName string) string {
+	if a.Bucket != "" {
+		return a.Bucket
+	}
+	return fmt.Sprintf("%s-%s", getGenericRemoteName(a.ProjectId), toolName)
+}
+
+// Path of the file holding the configuration for a single endpoint
+// for tools where configPath is a directory
+func getEndpointFilePath(configDir string, remoteName string, suffix string) string {
+	return filepath.Join(configDir, remoteName+suffix)
+}
+
+// Inverse of getEndpointFilePath, an empty suffix matches every file
+func listEndpointFiles(configDir string, suffix string) ([]string, error) {
+	entries, err := os.ReadDir(configDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var remoteNames []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), suffix) {
+			remoteNames = append(remoteNames, strings.TrimSuffix(entry.Name(), suffix))
+		}
+	}
+	return remoteNames, nil
+}
+
 func DeleteConfigSection(programArgs Settings, toolMap map[string]*ToolSettings) error {
 
 	sectionsToDelete := util.RemoveWhiteSpaceAndSplit(programArgs.DeleteList)
@@ -57,7 +90,8 @@ func DeleteConfigSection(programArgs Settings, toolMap map[string]*ToolSettings)
 	} else {
 		fmt.Printf("Using --nonintercative, assuming yes\n")
 	}
-	for _, tool := range toolMap {
+	for _, toolName := range util.SortedKeys(toolMap) {
+		tool := toolMap[toolName]
 		if !tool.IsEnabled {
 			if util.GlobalDebugFlag {
 				fmt.Printf("Ignoring configuration for %s\n", tool.Name)
@@ -66,30 +100,31 @@ func DeleteConfigSection(programArgs Settings, toolMap map[string]*ToolSettings)
 		} else {
 			currentu, _ := user.Current()
 			config := strings.Replace(tool.configPath, "~", currentu.HomeDir, 1)
-			err = util.DeleteIniSectionsFromFile(config, sectionsToDelete)
+			err = tool.backend.Delete(config, sectionsToDelete)
 			if err != nil {
-				return fmt.Errorf("failed deleting section in %s, error is: %s", config, err.Error())
-			}
-			// Extra logic for deleting configuration for aws
-			if tool.Name == "aws" {
-
-				var toDel []string
-				for _, x := range sectionsToDelete {
-					toDel = append(toDel, strings.Join([]string{"services", x}, " "))
-				}
-
-				err = deleteAwsEntry(getAwsConfigFilePath(config), toDel)
-				if err != nil {
-					return err
-				}
+				return fmt.Errorf("failed deleting %s endpoints in %s, error is: %s", tool.Name, config, err.Error())
 			}
-			if tool.Name == "s3cmd" {
-				err = deleteExtraS3cmdConfig(tool.configPath, sectionsToDelete)
-				if err != nil {
-					return err
-				}
+		}
+	}
+	return nil
+}
 
-			}
+func ListConfiguredEndpoints(toolMap map[string]*ToolSettings) error {
+	currentu, _ := user.Current()
+	for _, toolName := range util.SortedKeys(toolMap) {
+		tool := toolMap[toolName]
+		if !tool.IsEnabled {
+			continue
+		}
+		config := strings.Replace(tool.configPath, "~", currentu.HomeDir, 1)
+		endpoints, err := tool.backend.ListEndpoints(config)
+		if err != nil {
+			return fmt.Errorf("failed listing %s endpoints in %s, error is: %s", tool.Name, config, err.Error())
+		}
+		if config == "" {
+			fmt.Printf("%s: %s\n", tool.Name, strings.Join(endpoints, " "))
+		} else {
+			fmt.Printf("%s (%s): %s\n", tool.Name, config, strings.Join(endpoints, " "))
 		}
 	}
 	return nil
@@ -110,6 +145,13 @@ func ValidateRemote(tmpConfigPath string, remoteName string, commandName string,
 	return "", nil
 }
 
+// Validation of the temporary config by the backend of the tool
+func validatorFor(b Backend, s3auth AuthInfo) validationFunc {
+	return func(path string, name string) error {
+		return b.Validate(s3auth, path, name)
+	}
+}
+
 func parseKeepMapping(confArg string) (map[string]bool, error) {
 	stringMap := util.RemoveWhiteSpaceAndSplit(confArg)
 	mappings := make(map[string]bool)
@@ -139,12 +181,6 @@ func parseConfigPathMapping(confArg string) (map[string]string, error) {
 			newErr := errors.New(fmt.Sprintf("Incorrect format for argument to --config-path. Is %s, should be tool1:path1,tool2:path2", confArg))
 			return nil, newErr
 		} else {
-
-			// We were passed a directory or something which looks like a directory
-			if strings.HasSuffix(m[1], "/") || util.IsDirectory(m[1]) {
-				newErr := errors.New(fmt.Sprintf("Incorrect argument to --config-path. %s:%s\n\tPath can not end with / or be an existing directory. Specify the full path to the config file", m[0], m[1]))
-				return nil, newErr
-			}
 			mappings[m[0]] = m[1]
 		}
 	}
@@ -168,13 +204,23 @@ func ParseCommandlineArguments(settings *Settings, toolMap map[string]*ToolSetti
 	flag.StringVar(&skipValidation, "skip-validation", "", `Comma separated list of tools to skip validation for. WARNING: Might lead to a broken config`)
 	flag.StringVar(&keepDefault, "set-default", "", "Comma separated list of tools to switch defaults for. Default value: s3cmd:true,aws:false")
 	flag.StringVar(&configuredTools, "configure-only", "", "Comma separated list of tools to create configurations for. Default is rclone and s3cmd")
-	flag.IntVar(&settings.Chunksize, "chunksize", 15, `s3cmd and aws cli chunk size, 5-5000, Files larger than SIZE, in MB, are automatically uploaded multithread-multipart (default: 15)`)
+	flag.IntVar(&settings.Chunksize, "chunksize", 15, `Chunk size for the tools using one, e.g s3cmd, s5cmd, hadoop, nextflow and aws cli, 5-5000, Files larger than SIZE, in MB, are automatically uploaded multithread-multipart (default: 15)`)
 	flag.BoolVar(&util.GlobalDebugFlag, "debug", false, "Keep temporary configs for debugging and display additional output")
 	flag.IntVar(&settings.ProjectId, "project-number", 0, "Define LUMI-project to be used")
 	flag.BoolVar(&settings.NonInteractive, "noninteractive", false, "Read access and secret keys from environment: LUMIO_S3_ACCESS,LUMIO_S3_SECRET")
 	flag.StringVar(&customRemoteName, "remote-name", "", "Custom name for the endpoints, rclone public remote name will include a -public suffix")
+	flag.BoolVar(&rcloneCryptRemote, "rclone-crypt", false, "Also create an rclone crypt remote <remote-name>-crypt for client side encryption, wrapping the private remote. Password and salt are read from LUMIO_RCLONE_CRYPT_PASSWORD,LUMIO_RCLONE_CRYPT_SALT when using --noninteractive")
+	flag.StringVar(&kopiaPrefix, "kopia-prefix", "", "Object prefix for the kopia repository inside the bucket")
+	flag.StringVar(&dvcPrefix, "dvc-prefix", "", "Path prefix for the DVC remote inside the bucket")
+	flag.StringVar(&kubernetesNamespace, "kubernetes-namespace", "", "Namespace set in the generated Kubernetes manifests")
+	flag.BoolVar(&kubernetesConfigMap, "kubernetes-configmap", false, "Also add a ConfigMap with the endpoint and project number to the Kubernetes manifest")
+	flag.StringVar(&modulefileFormat, "modulefile-format", "lua", "Format of the generated modulefile, lua for Lmod or tcl")
 	flag.StringVar(&settings.DeleteList, "delete", "", "Comma separated list of endpoints to delete")
+	flag.BoolVar(&settings.ListEndpoints, "list", false, "List the endpoints found in the configs of the selected tools")
 	flag.StringVar(&settings.Url, "url", systemDefaultS3Url, "Url for the s3 object storage")
+	flag.StringVar(&settings.Bucket, "bucket", "", "Bucket used by tools which operate on a single bucket, e.g restic. Default: <remote-name>-<tool>")
+	flag.StringVar(&settings.EnvFormat, "env", "", "Print environment variables for the project instead of configuring tools. Format is one of sh, fish, csh, dotenv")
+	flag.StringVar(&settings.EnvFile, "env-file", "", "Write the variables from --env to this file instead of printing them")
 	flag.BoolVar(&settings.ShowVersion, "version", false, "Show version information and exit")
 	util.SetCustomHelp()
 	flag.Parse()
@@ -212,27 +258,37 @@ func ParseCommandlineArguments(settings *Settings, toolMap map[string]*ToolSetti
 	if err != nil {
 		return err
 	}
-	if toolMap["s3cmd"].NoReplace && toolMap["s3cmd"].configPath != systemDefaultConfigPaths["s3cmd"] {
-		fmt.Printf("WARNING: Using --keep-default s3cmd together with --s3cmd-config has no effect\n")
+	for _, toolName := range util.SortedKeys(toolMap) {
+		if checker, ok := toolMap[toolName].backend.(argumentChecker); ok {
+			err = checker.CheckArguments(*toolMap[toolName])
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	// Chuncksize option is not used for rlcone so don't verify unless needed.
-	if toolMap["s3cmd"].IsEnabled || toolMap["aws"].IsEnabled {
-		return validateChunksize(settings)
+	// Chuncksize option is not used for all tools so don't verify unless needed.
+	for _, tool := range toolMap {
+		if tool.IsEnabled && tool.backend.NeedsChunksize() {
+			return validateChunksize(settings)
+		}
 	}
 
 	return nil
 }
 
 func checkIfPresent(toolMap map[string]*ToolSettings) {
-	for k := range toolMap {
-		_, err := exec.LookPath(k)
-		if err != nil {
-			toolMap[k].IsPresent = false
-		} else {
-			toolMap[k].IsPresent = true
+	for k, tool := range toolMap {
+		commands := tool.commands
+		if len(commands) == 0 {
+			commands = []string{k}
+		}
+		tool.IsPresent = tool.noCommand
+		for _, command := range commands {
+			if _, err := exec.LookPath(command); err == nil {
+				tool.IsPresent = true
+			}
 		}
-
 	}
 }
 
@@ -290,7 +346,18 @@ func setConfigPaths(pathM string, available []string, toolMap map[string]*ToolSe
 	for k, v := range configPaths {
 		if !util.StringInSlice(k, available[:]) {
 			return errors.New(fmt.Sprintf("Unknown toolname %s in --config-path.", k))
+		} else if toolMap[k].configIsDir {
+			// Tools writing one file per endpoint take the directory holding those files
+			currentu, _ := user.Current()
+			if util.CheckFileExists(strings.Replace(v, "~", currentu.HomeDir, 1)) && !util.IsDirectory(v) {
+				return errors.New(fmt.Sprintf("Incorrect argument to --config-path. %s:%s\n\t%s stores one file per endpoint, specify a directory", k, v, k))
+			}
+			toolMap[k].configPath = strings.TrimSuffix(v, "/")
 		} else {
+			// We were passed a directory or something which looks like a directory
+			if strings.HasSuffix(v, "/") || util.IsDirectory(v) {
+				return errors.New(fmt.Sprintf("Incorrect argument to --config-path. %s:%s\n\tPath can not end with / or be an existing directory. Specify the full path to the config file", k, v))
+			}
 			toolMap[k].configPath = v
 		}
 
@@ -385,9 +452,37 @@ func GetUserInput(a *AuthInfo, argProjId int) error {
 	a.s3SecretKey = string(bytepw)
 	a.s3AccessKey = strings.TrimSpace(a.s3AccessKey)
 	a.s3SecretKey = strings.TrimSpace(a.s3SecretKey)
+	for _, reader := range enabledInputReaders() {
+		err = reader.ReadUserInput(a)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Backends of the enabled tools which need extra input
+func enabledInputReaders() []inputReader {
+	var readers []inputReader
+	for _, toolName := range util.SortedKeys(toolRegistry) {
+		tool := toolRegistry[toolName]
+		if reader, ok := tool.backend.(inputReader); ok && tool.IsEnabled {
+			readers = append(readers, reader)
+		}
+	}
+	return readers
+}
+
+// Prompts for a value without echoing it
+func readSecretInput(prompt string) (string, error) {
+	fmt.Printf("%s\n", prompt)
+	bytepw, err := term.ReadPassword(syscall.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytepw)), nil
+}
+
 func GetNonInteractiveInput(a *AuthInfo, argProjId int) error {
 	projectIdEnvVal, projectIdEnvValIsPresent := os.LookupEnv("LUMIO_PROJECTID")
 	var err error
@@ -418,6 +513,9 @@ func GetNonInteractiveInput(a *AuthInfo, argProjId int) error {
 		err := errors.New("Both LUMIO_S3_ACCESS and LUMIO_S3_SECRET need to be set when running in noninteractive mode ")
 		return err
 	}
+	for _, reader := range enabledInputReaders() {
+		reader.ReadNonInteractiveInput(a)
+	}
 
 	return nil
 }
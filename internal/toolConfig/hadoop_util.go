@@ -0,0 +1,100 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedHadoopRemoteValidationMessage = `Created s3a configuration %s for project_%d
+	Use it by setting HADOOP_CONF_DIR=%s
+	%s
+`
+
+// Without a bucket the options apply to all buckets, so only one project can be configured
+func getHadoopPropertyPrefix(a AuthInfo) string {
+	if a.Bucket == "" {
+		return "fs.s3a."
+	}
+	return fmt.Sprintf("fs.s3a.bucket.%s.", a.Bucket)
+}
+
+func getHadoopSetting(a AuthInfo) map[string]map[string]string {
+	prefix := getHadoopPropertyPrefix(a)
+	hadoopSettings := make(map[string]map[string]string)
+	hadoopSettings[getGenericRemoteName(a.ProjectId)] = map[string]string{
+		prefix + "endpoint":               a.Url,
+		prefix + "path.style.access":      "true",
+		prefix + "connection.ssl.enabled": fmt.Sprintf("%t", !strings.HasPrefix(a.Url, "http://")),
+		prefix + "access.key":             a.s3AccessKey,
+		prefix + "secret.key":             a.s3SecretKey,
+		prefix + "multipart.size":         fmt.Sprintf("%dM", a.Chunksize)}
+	return hadoopSettings
+}
+
+// Listing needs both hadoop and a bucket, otherwise only check the keys
+func ValidateHadoopRemote(hadoopConfigFilePath string, a AuthInfo) error {
+	_, err := exec.LookPath("hadoop")
+	if err != nil || a.Bucket == "" {
+		return util.CheckS3Credentials(a.Url, a.s3AccessKey, a.s3SecretKey)
+	}
+	return util.CheckCommand("hadoop", "--config", filepath.Dir(hadoopConfigFilePath), "fs", "-ls", fmt.Sprintf("s3a://%s/", a.Bucket))
+}
+
+type hadoopBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&HadoopSettings, hadoopBackend{})
+}
+
+func (hadoopBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateHadoopRemote(configPath, s3auth)
+}
+
+func (hadoopBackend) NeedsChunksize() bool {
+	return true
+}
+
+func (b hadoopBackend) Configure(s3auth AuthInfo, tmpDir string, hadoopSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	hadoopConfigPath := strings.Replace(hadoopSettings.configPath, "~", currentu.HomeDir, 1)
+	// hadoop takes the directory of the config, the file name is fixed
+	tmpHadoopConfig := fmt.Sprintf("%s/hadoop/core-site.xml", tmpDir)
+	err := os.MkdirAll(filepath.Dir(tmpHadoopConfig), 0700)
+	if err != nil {
+		return "Failed creating temporary hadoop config directory", err
+	}
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	info, err := util.UpdateXmlConfig(getHadoopSetting(s3auth), hadoopConfigPath, tmpHadoopConfig, hadoopSettings.carefullUpdate, hadoopSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpHadoopConfig, remoteName, "hadoop", validatorFor(b, s3auth), hadoopSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpHadoopConfig, hadoopConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	usage := "Options apply to all s3a:// buckets, use --bucket to configure several projects"
+	if s3auth.Bucket != "" {
+		usage = fmt.Sprintf("Options apply to s3a://%s/", s3auth.Bucket)
+	}
+	fmt.Printf("Updated hadoop config %s\n\n", hadoopConfigPath)
+	fmt.Printf(passedHadoopRemoteValidationMessage, remoteName, s3auth.ProjectId, filepath.Dir(hadoopConfigPath), usage)
+	return "", nil
+}
+
+func (hadoopBackend) Delete(configPath string, sectionNames []string) error {
+	return util.DeleteXmlSectionsFromFile(configPath, sectionNames)
+}
+
+func (hadoopBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListXmlSections(configPath)
+}
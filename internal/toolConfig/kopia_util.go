@@ -0,0 +1,150 @@
+package toolConfig
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedKopiaValidationMessage = `Created kopia repository connection %s for project_%d
+	Repository: s3://%s/%s
+	kopia --config-file %s snapshot create <directory>
+`
+const kopiaNoRepositoryMessage = `No kopia repository exists yet in bucket %s
+	Create it with: kopia repository create from-config --file %s
+`
+
+// Set when parsing commandline arguments
+var kopiaPrefix = ""
+
+// Errors from kopia when the storage is reachable but the repository has not been created
+var kopiaNoRepositoryErrors = []string{"repository not initialized", "BLOB not found"}
+
+// Kopia stores the password next to the config when the keyring is not used
+func getKopiaPasswordFilePath(kopiaConfigFilePath string) string {
+	return kopiaConfigFilePath + ".kopia-password"
+}
+
+func getKopiaSetting(a AuthInfo) map[string]map[string]any {
+	currentu, _ := user.Current()
+	remoteName := getGenericRemoteName(a.ProjectId)
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(a.Url, "https://"), "http://")
+	return map[string]map[string]any{
+		"storage": {
+			"type": "s3",
+			"config": map[string]any{
+				"bucket":          getBucketName(a, "kopia"),
+				"prefix":          kopiaPrefix,
+				"endpoint":        strings.TrimSuffix(endpoint, "/"),
+				"doNotUseTLS":     strings.HasPrefix(a.Url, "http://"),
+				"accessKeyID":     a.s3AccessKey,
+				"secretAccessKey": a.s3SecretKey}},
+		"caching": {
+			"cacheDirectory": filepath.Join(currentu.HomeDir, ".cache", "kopia", remoteName)}}
+}
+
+func ValidateKopiaRepository(kopiaConfigFilePath string, bucketName string) error {
+	err := util.CheckCommand("kopia", "--config-file", kopiaConfigFilePath, "repository", "status")
+	if err == nil {
+		return nil
+	}
+	// Valid credentials but the repository has not been created
+	for _, kopiaError := range kopiaNoRepositoryErrors {
+		if strings.Contains(err.Error(), kopiaError) {
+			fmt.Printf(kopiaNoRepositoryMessage, bucketName, kopiaConfigFilePath)
+			return nil
+		}
+	}
+	return err
+}
+
+type kopiaBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&KopiaSettings, kopiaBackend{})
+}
+
+func (kopiaBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateKopiaRepository(configPath, getBucketName(s3auth, "kopia"))
+}
+
+func (kopiaBackend) ReadUserInput(a *AuthInfo) error {
+	var err error
+	a.kopiaPassword, err = readSecretInput("Password for the kopia repository (leave empty to keep the existing one)")
+	return err
+}
+
+func (kopiaBackend) ReadNonInteractiveInput(a *AuthInfo) {
+	a.kopiaPassword = os.Getenv("LUMIO_KOPIA_PASSWORD")
+}
+
+func (b kopiaBackend) Configure(s3auth AuthInfo, tmpDir string, kopiaSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	kopiaConfigDir := strings.Replace(kopiaSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	kopiaConfigPath := getEndpointFilePath(kopiaConfigDir, remoteName, ".config")
+	kopiaPasswordPath := getKopiaPasswordFilePath(kopiaConfigPath)
+	tmpKopiaConfig := fmt.Sprintf("%s/temp_kopia.config", tmpDir)
+	tmpKopiaPassword := getKopiaPasswordFilePath(tmpKopiaConfig)
+
+	if s3auth.kopiaPassword != "" {
+		err := os.WriteFile(tmpKopiaPassword, []byte(base64.StdEncoding.EncodeToString([]byte(s3auth.kopiaPassword))), 0600)
+		if err != nil {
+			return "Failed writing temporary kopia password file", err
+		}
+	} else if util.CheckFileExists(kopiaPasswordPath) {
+		inf, err := util.CommitTempConfigFile(kopiaPasswordPath, tmpKopiaPassword)
+		if err != nil {
+			return inf, err
+		}
+	} else {
+		return "A password is needed for the kopia repository", errors.New("no kopia password given, and no existing password found")
+	}
+	info, err := util.UpdateJsonConfig(getKopiaSetting(s3auth), "", kopiaConfigPath, tmpKopiaConfig, kopiaSettings.carefullUpdate, kopiaSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpKopiaConfig, remoteName, "kopia", validatorFor(b, s3auth), kopiaSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpKopiaPassword, kopiaPasswordPath)
+	if err != nil {
+		return fmt.Sprintf("While saving kopia password, %s", inf), err
+	}
+	inf, err = util.CommitTempConfigFile(tmpKopiaConfig, kopiaConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated kopia config %s\n\n", kopiaConfigPath)
+	fmt.Printf(passedKopiaValidationMessage, remoteName, s3auth.ProjectId, getBucketName(s3auth, "kopia"), kopiaPrefix, kopiaConfigPath)
+	return "", nil
+}
+
+func (kopiaBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		configFile := getEndpointFilePath(configDir, sectionName, ".config")
+		if !util.CheckFileExists(configFile) {
+			fmt.Printf("WARNING: While deleting kopia endpoint %s, no such file %s\n", sectionName, configFile)
+			continue
+		}
+		for _, file := range []string{configFile, getKopiaPasswordFilePath(configFile)} {
+			err := os.Remove(file)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		fmt.Printf("Deleted kopia repository connection %s\n", configFile)
+	}
+	return nil
+}
+
+func (kopiaBackend) ListEndpoints(configDir string) ([]string, error) {
+	return listEndpointFiles(configDir, ".config")
+}
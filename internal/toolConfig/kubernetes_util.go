@@ -0,0 +1,182 @@
+package toolConfig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const passedKubernetesValidationMessage = `Created Kubernetes manifest %s for project_%d
+	Nothing has been applied to a cluster, review the manifest and apply it with:
+	kubectl apply -f %s
+`
+
+// Set when parsing commandline arguments
+var kubernetesNamespace = ""
+var kubernetesConfigMap = false
+
+type kubernetesMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+type kubernetesManifest struct {
+	ApiVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Type       string             `yaml:"type,omitempty"`
+	Data       map[string]string  `yaml:"data"`
+}
+
+func getKubernetesMetadata(name string) kubernetesMetadata {
+	return kubernetesMetadata{
+		Name:      name,
+		Namespace: kubernetesNamespace,
+		Labels:    map[string]string{"app.kubernetes.io/managed-by": "lumio-conf"}}
+}
+
+func getKubernetesSetting(a AuthInfo) []kubernetesManifest {
+	remoteName := getGenericRemoteName(a.ProjectId)
+	values := map[string]string{
+		"AWS_ACCESS_KEY_ID":     a.s3AccessKey,
+		"AWS_SECRET_ACCESS_KEY": a.s3SecretKey,
+		"AWS_ENDPOINT_URL":      a.Url,
+		"LUMI_PROJECT_ID":       fmt.Sprintf("%d", a.ProjectId)}
+	secretData := make(map[string]string)
+	for k, v := range values {
+		secretData[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	manifests := []kubernetesManifest{{
+		ApiVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   getKubernetesMetadata(fmt.Sprintf("%s-s3-credentials", remoteName)),
+		Type:       "Opaque",
+		Data:       secretData}}
+	// Only the values which are not secret
+	if kubernetesConfigMap {
+		manifests = append(manifests, kubernetesManifest{
+			ApiVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata:   getKubernetesMetadata(fmt.Sprintf("%s-s3-config", remoteName)),
+			Data: map[string]string{
+				"AWS_ENDPOINT_URL": values["AWS_ENDPOINT_URL"],
+				"LUMI_PROJECT_ID":  values["LUMI_PROJECT_ID"]}})
+	}
+	return manifests
+}
+
+func writeKubernetesManifests(filename string, manifests []kubernetesManifest) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, manifest := range manifests {
+		err := encoder.Encode(manifest)
+		if err != nil {
+			return err
+		}
+	}
+	encoder.Close()
+	return os.WriteFile(filename, buf.Bytes(), 0600)
+}
+
+// The manifest is never applied, instead the keys in the Secret are
+// decoded and checked against the endpoint
+func ValidateKubernetesManifest(manifestFilePath string, remoteName string) error {
+	data, err := os.ReadFile(manifestFilePath)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var manifest kubernetesManifest
+		err = decoder.Decode(&manifest)
+		if errors.Is(err, io.EOF) {
+			return errors.New(fmt.Sprintf("no Secret found in %s", manifestFilePath))
+		}
+		if err != nil {
+			return err
+		}
+		if manifest.Kind != "Secret" {
+			continue
+		}
+		values := make(map[string]string)
+		for k, v := range manifest.Data {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return errors.New(fmt.Sprintf("value for %s is not base64 encoded", k))
+			}
+			values[k] = string(decoded)
+		}
+		return util.CheckS3Credentials(values["AWS_ENDPOINT_URL"], values["AWS_ACCESS_KEY_ID"], values["AWS_SECRET_ACCESS_KEY"])
+	}
+}
+
+type kubernetesBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&KubernetesSettings, kubernetesBackend{})
+}
+
+func (kubernetesBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateKubernetesManifest(configPath, remoteName)
+}
+
+func (b kubernetesBackend) Configure(s3auth AuthInfo, tmpDir string, kubernetesSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	kubernetesConfigDir := strings.Replace(kubernetesSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	kubernetesConfigPath := getEndpointFilePath(kubernetesConfigDir, remoteName, ".yaml")
+	tmpKubernetesConfig := fmt.Sprintf("%s/temp_kubernetes.yaml", tmpDir)
+
+	err := writeKubernetesManifests(tmpKubernetesConfig, getKubernetesSetting(s3auth))
+	if err != nil {
+		return "Failed writing temporary Kubernetes manifest", err
+	}
+	info, err := ValidateRemote(tmpKubernetesConfig, remoteName, "kubernetes", validatorFor(b, s3auth), kubernetesSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpKubernetesConfig, kubernetesConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+	// An existing file keeps its permissions when overwritten
+	err = os.Chmod(kubernetesConfigPath, 0600)
+	if err != nil {
+		return fmt.Sprintf("failed chmod on %s", kubernetesConfigPath), err
+	}
+
+	fmt.Printf("Updated Kubernetes manifest %s\n\n", kubernetesConfigPath)
+	fmt.Printf(passedKubernetesValidationMessage, remoteName, s3auth.ProjectId, kubernetesConfigPath)
+	return "", nil
+}
+
+func (kubernetesBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		manifestFile := getEndpointFilePath(configDir, sectionName, ".yaml")
+		if !util.CheckFileExists(manifestFile) {
+			fmt.Printf("WARNING: While deleting kubernetes endpoint %s, no such file %s\n", sectionName, manifestFile)
+			continue
+		}
+		err := os.Remove(manifestFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted Kubernetes manifest %s\n", manifestFile)
+		fmt.Printf("WARNING: Objects already applied to a cluster are not removed\n")
+	}
+	return nil
+}
+
+func (kubernetesBackend) ListEndpoints(configDir string) ([]string, error) {
+	return listEndpointFiles(configDir, ".yaml")
+}
@@ -0,0 +1,82 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedMcRemoteValidationMessage = `Created mc alias %s for project_%d
+	mc ls %s/<bucket_name>
+`
+
+// Config format version used by current mc releases
+const mcConfigVersion = "10"
+
+func ValidateMcRemote(mcConfigFilePath string, remoteName string) error {
+	return util.CheckCommand("mc", "--config-dir", filepath.Dir(mcConfigFilePath), "ls", remoteName)
+}
+
+func getMcSetting(a AuthInfo) map[string]map[string]any {
+	mcSettings := make(map[string]map[string]any)
+	mcSettings[getGenericRemoteName(a.ProjectId)] = map[string]any{
+		"url":       a.Url,
+		"accessKey": a.s3AccessKey,
+		"secretKey": a.s3SecretKey,
+		"api":       "S3v4",
+		"path":      "on"}
+	return mcSettings
+}
+
+type mcBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&McSettings, mcBackend{})
+}
+
+func (mcBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateMcRemote(configPath, remoteName)
+}
+
+func (b mcBackend) Configure(s3auth AuthInfo, tmpDir string, mcSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	mcConfigPath := strings.Replace(mcSettings.configPath, "~", currentu.HomeDir, 1)
+	// mc takes the directory of the config, the file name is fixed
+	tmpMcConfig := fmt.Sprintf("%s/mc/config.json", tmpDir)
+	err := os.MkdirAll(filepath.Dir(tmpMcConfig), 0700)
+	if err != nil {
+		return "Failed creating temporary mc config directory", err
+	}
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	info, err := util.UpdateJsonConfig(getMcSetting(s3auth), "aliases", mcConfigPath, tmpMcConfig, mcSettings.carefullUpdate, mcSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	err = util.SetJsonDefault(tmpMcConfig, "version", mcConfigVersion)
+	if err != nil {
+		return "Failed setting mc config version", err
+	}
+	info, err = ValidateRemote(tmpMcConfig, remoteName, "mc", validatorFor(b, s3auth), mcSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpMcConfig, mcConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated mc config %s\n\n", mcConfigPath)
+	fmt.Printf(passedMcRemoteValidationMessage, remoteName, s3auth.ProjectId, remoteName)
+	return "", nil
+}
+
+func (mcBackend) Delete(configPath string, sectionNames []string) error {
+	return util.DeleteJsonSectionsFromFile(configPath, "aliases", sectionNames)
+}
+
+func (mcBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListJsonSections(configPath, "aliases")
+}
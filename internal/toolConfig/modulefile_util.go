@@ -0,0 +1,200 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedModulefileValidationMessage = `Created modulefile %s for project_%d
+	Loading it switches all tools to the project, without changing the default configurations
+	module use %s
+	module load lumio/%s
+`
+const modulefileMissingConfigMessage = `WARNING: The module points to %s, which does not exist yet
+`
+
+// Set when parsing commandline arguments
+var modulefileFormat = "lua"
+
+var modulefileSuffixes = map[string]string{
+	"lua": ".lua",
+	"tcl": ""}
+
+const luaModulefileTemplate = `-- LUMI-O configuration for project_%d, generated by lumio-conf
+whatis("Use LUMI-O storage of project_%d")
+help([[Sets the environment for rclone, s3cmd and aws to use LUMI-O storage of project_%d]])
+family("lumio")
+`
+const tclModulefileTemplate = `#%%Module1.0
+## LUMI-O configuration for project_%d, generated by lumio-conf
+module-whatis "Use LUMI-O storage of project_%d"
+proc ModulesHelp { } {
+    puts stderr "Sets the environment for rclone, s3cmd and aws to use LUMI-O storage of project_%d"
+}
+conflict lumio
+`
+
+func getS3cmdConfigPathForProject(remoteName string) string {
+	currentu, _ := user.Current()
+	s3cmdConfigPath := strings.Replace(S3cmdSettings.configPath, "~", currentu.HomeDir, 1)
+	// Same logic as in s3cmdBackend.Configure
+	if S3cmdSettings.configPath == systemDefaultConfigPaths["s3cmd"] {
+		return fmt.Sprintf("%s-%s", s3cmdConfigPath, remoteName)
+	}
+	return s3cmdConfigPath
+}
+
+func getModulefileSetting(a AuthInfo) map[string]string {
+	currentu, _ := user.Current()
+	remoteName := getGenericRemoteName(a.ProjectId)
+	awsCredentialsPath := strings.Replace(AwsSettings.configPath, "~", currentu.HomeDir, 1)
+	return map[string]string{
+		"AWS_PROFILE":                 remoteName,
+		"AWS_SHARED_CREDENTIALS_FILE": awsCredentialsPath,
+		"AWS_CONFIG_FILE":             getAwsConfigFilePath(awsCredentialsPath),
+		"AWS_ENDPOINT_URL":            a.Url,
+		"S3_ENDPOINT":                 a.Url,
+		"S3CMD_CONFIG":                getS3cmdConfigPathForProject(remoteName),
+		"RCLONE_CONFIG":               strings.Replace(RcloneSettings.configPath, "~", currentu.HomeDir, 1),
+		"LUMI_PROJECT_ID":             fmt.Sprintf("%d", a.ProjectId)}
+}
+
+func getModulefile(a AuthInfo, format string) string {
+	variables := getModulefileSetting(a)
+	var lines []string
+	if format == "lua" {
+		lines = append(lines, fmt.Sprintf(luaModulefileTemplate, a.ProjectId, a.ProjectId, a.ProjectId))
+		for _, k := range util.SortedKeys(variables) {
+			lines = append(lines, fmt.Sprintf(`setenv("%s", "%s")`, k, variables[k]))
+		}
+	} else {
+		lines = append(lines, fmt.Sprintf(tclModulefileTemplate, a.ProjectId, a.ProjectId, a.ProjectId))
+		for _, k := range util.SortedKeys(variables) {
+			lines = append(lines, fmt.Sprintf(`setenv %s "%s"`, k, variables[k]))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Lmod can show a modulefile given with its full path
+func ValidateModulefile(modulefilePath string, remoteName string) error {
+	lmodCommand, found := os.LookupEnv("LMOD_CMD")
+	if !found {
+		fmt.Printf("Lmod not found, skipping validation of the modulefile\n")
+		return nil
+	}
+	return util.CheckCommand(lmodCommand, "bash", "show", modulefilePath)
+}
+
+type modulefileBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&ModulefileSettings, modulefileBackend{})
+}
+
+func (modulefileBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateModulefile(configPath, remoteName)
+}
+
+func (modulefileBackend) CheckArguments(modulefileSettings ToolSettings) error {
+	if _, found := modulefileSuffixes[modulefileFormat]; !found && modulefileSettings.IsEnabled {
+		return errors.New(fmt.Sprintf("Unknown format %s for --modulefile-format. Valid options are: %s", modulefileFormat, strings.Join(util.SortedKeys(modulefileSuffixes), " ")))
+	}
+	return nil
+}
+
+func (b modulefileBackend) Configure(s3auth AuthInfo, tmpDir string, modulefileSettings ToolSettings) (string, error) {
+	suffix := modulefileSuffixes[modulefileFormat]
+	currentu, _ := user.Current()
+	modulefileDir := strings.Replace(modulefileSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	modulefilePath := getEndpointFilePath(modulefileDir, remoteName, suffix)
+	tmpModulefile := fmt.Sprintf("%s/temp_modulefile%s", tmpDir, suffix)
+
+	err := os.WriteFile(tmpModulefile, []byte(getModulefile(s3auth, modulefileFormat)), 0600)
+	if err != nil {
+		return "Failed writing temporary modulefile", err
+	}
+	info, err := ValidateRemote(tmpModulefile, remoteName, "modulefile", validatorFor(b, s3auth), modulefileSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpModulefile, modulefilePath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+	// Only one format per project, Lmod would prefer the lua file
+	for _, otherSuffix := range modulefileSuffixes {
+		otherPath := getEndpointFilePath(modulefileDir, remoteName, otherSuffix)
+		if otherSuffix != suffix && util.CheckFileExists(otherPath) {
+			err = os.Remove(otherPath)
+			if err != nil {
+				return fmt.Sprintf("Failed removing old modulefile %s", otherPath), err
+			}
+		}
+	}
+
+	fmt.Printf("Updated modulefile %s\n\n", modulefilePath)
+	variables := getModulefileSetting(s3auth)
+	for _, k := range []string{"S3CMD_CONFIG", "RCLONE_CONFIG", "AWS_SHARED_CREDENTIALS_FILE"} {
+		if !util.CheckFileExists(variables[k]) {
+			fmt.Printf(modulefileMissingConfigMessage, variables[k])
+		}
+	}
+	fmt.Printf(passedModulefileValidationMessage, remoteName, s3auth.ProjectId, filepath.Dir(modulefileDir), remoteName)
+	return "", nil
+}
+
+func (modulefileBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		deleted := false
+		for _, suffix := range modulefileSuffixes {
+			modulefilePath := getEndpointFilePath(configDir, sectionName, suffix)
+			if !util.CheckFileExists(modulefilePath) {
+				continue
+			}
+			err := os.Remove(modulefilePath)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Deleted modulefile %s\n", modulefilePath)
+			deleted = true
+		}
+		if !deleted {
+			fmt.Printf("WARNING: While deleting modulefile %s, no such file in %s\n", sectionName, configDir)
+		}
+	}
+	return nil
+}
+
+// Tcl modulefiles have no suffix, they are recognized by the generated header
+func isGeneratedTclModulefile(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	header, _, _ := strings.Cut(strings.TrimPrefix(string(data), "#%Module1.0\n"), "\n")
+	return strings.HasPrefix(string(data), "#%Module1.0\n") && strings.HasSuffix(header, "generated by lumio-conf")
+}
+
+func (modulefileBackend) ListEndpoints(configDir string) ([]string, error) {
+	remoteNames, err := listEndpointFiles(configDir, modulefileSuffixes["lua"])
+	if err != nil {
+		return nil, err
+	}
+	files, err := listEndpointFiles(configDir, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !util.StringInSlice(file, remoteNames) && isGeneratedTclModulefile(filepath.Join(configDir, file)) {
+			remoteNames = append(remoteNames, file)
+		}
+	}
+	return remoteNames, nil
+}
@@ -0,0 +1,94 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+const passedNextflowRemoteValidationMessage = `Created Nextflow profile %s for project_%d
+	nextflow run <pipeline> -profile %s
+`
+
+const nextflowProfileTemplate = `profiles {
+    %s {
+        aws {
+            accessKey = %s
+            secretKey = %s
+            client {
+                endpoint = %s
+                s3PathStyleAccess = true
+                uploadChunkSize = '%d MB'
+            }
+        }
+    }
+}`
+
+// Single quoted Groovy strings are not interpolated, only quotes and backslashes are escaped
+func quoteGroovy(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+func getNextflowSetting(a AuthInfo) map[string]string {
+	remoteName := getGenericRemoteName(a.ProjectId)
+	return map[string]string{
+		remoteName: fmt.Sprintf(nextflowProfileTemplate, quoteGroovy(remoteName), quoteGroovy(a.s3AccessKey), quoteGroovy(a.s3SecretKey), quoteGroovy(a.Url), a.Chunksize)}
+}
+
+// Nextflow is only used for checking that the config can be parsed
+func ValidateNextflowRemote(nextflowConfigFilePath string, remoteName string, a AuthInfo) error {
+	if _, err := exec.LookPath("nextflow"); err == nil {
+		err = util.CheckCommand("nextflow", "-q", "-C", nextflowConfigFilePath, "config", "-profile", remoteName)
+		if err != nil {
+			return err
+		}
+	}
+	return util.CheckS3Credentials(a.Url, a.s3AccessKey, a.s3SecretKey)
+}
+
+type nextflowBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&NextflowSettings, nextflowBackend{})
+}
+
+func (nextflowBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateNextflowRemote(configPath, remoteName, s3auth)
+}
+
+func (nextflowBackend) NeedsChunksize() bool {
+	return true
+}
+
+func (b nextflowBackend) Configure(s3auth AuthInfo, tmpDir string, nextflowSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	nextflowConfigPath := strings.Replace(nextflowSettings.configPath, "~", currentu.HomeDir, 1)
+	tmpNextflowConfig := fmt.Sprintf("%s/temp_nextflow.config", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	info, err := util.UpdateBlockConfig(getNextflowSetting(s3auth), "//", nextflowConfigPath, tmpNextflowConfig)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpNextflowConfig, remoteName, "nextflow", validatorFor(b, s3auth), nextflowSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpNextflowConfig, nextflowConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated Nextflow config %s\n\n", nextflowConfigPath)
+	fmt.Printf(passedNextflowRemoteValidationMessage, remoteName, s3auth.ProjectId, remoteName)
+	return "", nil
+}
+
+func (nextflowBackend) Delete(configPath string, sectionNames []string) error {
+	return util.DeleteConfigBlocksFromFile(configPath, "//", sectionNames)
+}
+
+func (nextflowBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListConfigBlocks(configPath, "//")
+}
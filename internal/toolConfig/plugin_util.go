@@ -0,0 +1,208 @@
+package toolConfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Executables named lumio-conf-<tool> on PATH are added as tools,
+// the protocol is described in the README under Plugins
+const pluginPrefix = "lumio-conf-"
+
+const pluginProtocolVersion = 1
+
+type pluginRequest struct {
+	Version        int    `json:"version"`
+	Action         string `json:"action"`
+	ProjectId      int    `json:"project_id"`
+	Url            string `json:"url"`
+	AccessKey      string `json:"access_key,omitempty"`
+	SecretKey      string `json:"secret_key,omitempty"`
+	Chunksize      int    `json:"chunk_size"`
+	RemoteName     string `json:"remote_name"`
+	Bucket         string `json:"bucket"`
+	ConfigPath     string `json:"config_path"`
+	SkipValidation bool   `json:"skip_validation"`
+	SetDefault     bool   `json:"set_default"`
+	// Only for the delete action
+	Sections []string `json:"sections,omitempty"`
+	Debug    bool     `json:"debug"`
+}
+
+type pluginResponse struct {
+	Status    string   `json:"status"`
+	Message   string   `json:"message"`
+	Info      string   `json:"info"`
+	Hint      string   `json:"hint"`
+	Endpoints []string `json:"endpoints"`
+}
+
+type pluginError struct {
+	message string
+	hint    string
+}
+
+func (e *pluginError) Error() string {
+	return e.message
+}
+
+type pluginBackend struct {
+	executable string
+}
+
+var pluginsDiscovered = false
+
+// Built-in tools and plugins found earlier on PATH take precedence
+func discoverPlugins() {
+	if pluginsDiscovered {
+		return
+	}
+	pluginsDiscovered = true
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, found := strings.CutPrefix(entry.Name(), pluginPrefix)
+			// Names are used in the comma separated tool:value arguments
+			if !found || name == "" || strings.ContainsAny(name, ",: \t") {
+				continue
+			}
+			if _, exists := toolRegistry[name]; exists {
+				continue
+			}
+			executable := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(executable)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			registerTool(&ToolSettings{
+				configPath:         "",
+				Name:               name,
+				IsEnabled:          false,
+				IsPresent:          false,
+				ValidationDisabled: false,
+				NoReplace:          true,
+				carefullUpdate:     true,
+				singleSection:      false,
+				commands:           []string{executable},
+			}, pluginBackend{executable: executable})
+		}
+	}
+}
+
+// The chunk size is passed to every plugin
+func (pluginBackend) NeedsChunksize() bool {
+	return true
+}
+
+func newPluginRequest(action string, s3auth AuthInfo) pluginRequest {
+	return pluginRequest{
+		Version:    pluginProtocolVersion,
+		Action:     action,
+		ProjectId:  s3auth.ProjectId,
+		Url:        s3auth.Url,
+		AccessKey:  s3auth.s3AccessKey,
+		SecretKey:  s3auth.s3SecretKey,
+		Chunksize:  s3auth.Chunksize,
+		RemoteName: getGenericRemoteName(s3auth.ProjectId),
+		Bucket:     s3auth.Bucket,
+		Debug:      util.GlobalDebugFlag,
+	}
+}
+
+// The request is written to stdin and the response read from stdout,
+// stderr of the plugin is passed on to the user
+func (p pluginBackend) run(request pluginRequest) (pluginResponse, error) {
+	var response pluginResponse
+	input, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(p.executable)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	err = json.Unmarshal(output.Bytes(), &response)
+	if err != nil {
+		if runErr != nil {
+			return response, fmt.Errorf("plugin %s failed, error is: %s", p.executable, runErr.Error())
+		}
+		return response, fmt.Errorf("invalid response from plugin %s, error is: %s", p.executable, err.Error())
+	}
+	if response.Status != "ok" || runErr != nil {
+		message := response.Message
+		if message == "" && runErr != nil {
+			message = runErr.Error()
+		} else if message == "" {
+			message = fmt.Sprintf("plugin returned status %q", response.Status)
+		}
+		return response, &pluginError{message: message, hint: response.Hint}
+	}
+	return response, nil
+}
+
+func (p pluginBackend) Configure(s3auth AuthInfo, tmpDir string, pluginSettings ToolSettings) (string, error) {
+	request := newPluginRequest("configure", s3auth)
+	request.ConfigPath = pluginSettings.configPath
+	request.SkipValidation = pluginSettings.ValidationDisabled
+	request.SetDefault = !pluginSettings.NoReplace
+	response, err := p.run(request)
+	if err != nil {
+		if response.Info == "" {
+			return fmt.Sprintf("Failed configuring %s with plugin %s", pluginSettings.Name, p.executable), err
+		}
+		return response.Info, err
+	}
+	if response.Message != "" {
+		fmt.Printf("%s\n", response.Message)
+	}
+	if response.Info != "" {
+		fmt.Printf("%s\n", response.Info)
+	}
+	return "", nil
+}
+
+func (p pluginBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	request := newPluginRequest("validate", s3auth)
+	request.ConfigPath = configPath
+	request.RemoteName = remoteName
+	_, err := p.run(request)
+	return err
+}
+
+func (p pluginBackend) Delete(configPath string, sectionNames []string) error {
+	request := pluginRequest{Version: pluginProtocolVersion, Action: "delete", ConfigPath: configPath, Sections: sectionNames, Debug: util.GlobalDebugFlag}
+	response, err := p.run(request)
+	if err != nil {
+		return err
+	}
+	if response.Message != "" {
+		fmt.Printf("%s\n", response.Message)
+	}
+	return nil
+}
+
+func (p pluginBackend) ListEndpoints(configPath string) ([]string, error) {
+	request := pluginRequest{Version: pluginProtocolVersion, Action: "list", ConfigPath: configPath, Debug: util.GlobalDebugFlag}
+	response, err := p.run(request)
+	return response.Endpoints, err
+}
+
+func (p pluginBackend) ExplainError(err error) string {
+	var pErr *pluginError
+	if errors.As(err, &pErr) {
+		return pErr.hint
+	}
+	return ""
+}
@@ -1,11 +1,20 @@
 package toolConfig
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"lumioconf/internal/util"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
+
+	"gopkg.in/ini.v1"
 )
 
 const passedRcloneRemoteValdidationMessage = `rclone remote %s: now provides an S3 based connection to Lumi-O storage area of project_%d
@@ -13,6 +22,24 @@ const passedRcloneRemoteValdidationMessage = `rclone remote %s: now provides an
 rclone remote %s: now provides an S3 based connection to Lumi-O storage area of project_%d
 	Data pushed here is publicly available using the URL: https://%d.lumidata.eu/<bucket_name>/<object>"
 `
+const passedRcloneCryptRemoteValidationMessage = `
+rclone remote %s: now provides client side encryption for data stored in %s
+`
+const rcloneCryptSecretMessage = `Generated a new password and salt for the rclone crypt remote %s
+	IMPORTANT: They are stored obscured in %s, keep a copy (rclone reveal), encrypted data can not be recovered without them
+`
+
+// Set when parsing commandline arguments
+var rcloneCryptRemote = false
+
+// Key used by rclone for obscuring passwords in the config file
+// https://github.com/rclone/rclone/blob/master/fs/config/obscure/obscure.go
+var rcloneObscureKey = []byte{
+	0x9c, 0x93, 0x5b, 0x48, 0x73, 0x0a, 0x55, 0x4d,
+	0x6b, 0xfd, 0x7c, 0x63, 0xc8, 0x86, 0xa9, 0x2b,
+	0xd3, 0x90, 0x19, 0x8e, 0xb8, 0x12, 0x8a, 0xfb,
+	0xf4, 0xde, 0x16, 0x2b, 0x8b, 0x95, 0xf6, 0x38,
+}
 
 func getPublicRcloneRemoteName(projid int) string {
 	if customRemoteName != "" {
@@ -29,6 +56,29 @@ func getPrivateRcloneRemoteName(projid int) string {
 	}
 }
 
+func getCryptRcloneRemoteName(projid int) string {
+	if customRemoteName != "" {
+		return fmt.Sprintf("%s-crypt", customRemoteName)
+	} else {
+		return fmt.Sprintf("lumi-%d-crypt", projid)
+	}
+}
+
+// Same as rclone obscure
+func obscureRclonePassword(password string) (string, error) {
+	block, err := aes.NewCipher(rcloneObscureKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, aes.BlockSize+len(password))
+	iv := ciphertext[:aes.BlockSize]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext[aes.BlockSize:], []byte(password))
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
 func ValidateRcloneRemote(rcloneConfigFilePath string, remoteName string) error {
 	os.Setenv("RCLONE_CONFIG", rcloneConfigFilePath)
 	command_args := fmt.Sprintf("%s:", remoteName)
@@ -40,19 +90,117 @@ func ValidateRcloneRemote(rcloneConfigFilePath string, remoteName string) error
 		command_args)
 }
 
-func addRcloneRemotes(s3auth AuthInfo, tmpDir string, rcloneSettings ToolSettings) (string, error) {
+// Write a small object through the crypt remote and read it back.
+// The bucket holding the encrypted data is created if it does not exist
+func ValidateRcloneCryptRemote(rcloneConfigFilePath string, remoteName string) error {
+	env := []string{fmt.Sprintf("RCLONE_CONFIG=%s", rcloneConfigFilePath)}
+	cfg, err := ini.Load(rcloneConfigFilePath)
+	if err != nil {
+		return err
+	}
+	wrapped := cfg.Section(remoteName).Key("remote").String()
+	err = util.CheckCommandEnv(env, "rclone", "lsd", "--retries", "1", wrapped)
+	if err != nil && strings.Contains(err.Error(), "directory not found") {
+		err = util.CheckCommandEnv(env, "rclone", "mkdir", "--retries", "1", wrapped)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created bucket %s for the rclone crypt remote %s\n", wrapped, remoteName)
+	} else if err != nil {
+		return err
+	}
+	content, err := util.GenerateSecret(32)
+	if err != nil {
+		return err
+	}
+	localFile := filepath.Join(filepath.Dir(rcloneConfigFilePath), "crypt_validation")
+	err = os.WriteFile(localFile, []byte(content), 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(localFile)
+	remoteFile := fmt.Sprintf("%s:lumio-conf-validation-%s", remoteName, content[:8])
+	err = util.CheckCommandEnv(env, "rclone", "copyto", "--retries", "1", localFile, remoteFile)
+	if err != nil {
+		return err
+	}
+	defer util.CheckCommandEnv(env, "rclone", "deletefile", "--retries", "1", remoteFile)
+	readBack, err := util.CommandOutputEnv(env, "rclone", "cat", "--retries", "1", remoteFile)
+	if err != nil {
+		return err
+	}
+	if readBack != content {
+		return errors.New(fmt.Sprintf("data read through %s: does not match the data written", remoteName))
+	}
+	return nil
+}
+
+type rcloneBackend struct {
+	baseBackend
+	iniSections
+}
+
+func init() {
+	registerTool(&RcloneSettings, rcloneBackend{})
+}
+
+func (rcloneBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	if remoteName == getCryptRcloneRemoteName(s3auth.ProjectId) {
+		return ValidateRcloneCryptRemote(configPath, remoteName)
+	}
+	return ValidateRcloneRemote(configPath, remoteName)
+}
+
+// The crypt password and salt are generated when not given
+func (rcloneBackend) ReadUserInput(a *AuthInfo) error {
+	if !rcloneCryptRemote {
+		return nil
+	}
+	var err error
+	a.rcloneCryptPassword, err = readSecretInput("Password for the rclone crypt remote (leave empty to generate one or keep the existing one)")
+	if err != nil {
+		return err
+	}
+	a.rcloneCryptSalt, err = readSecretInput("Salt for the rclone crypt remote (leave empty to generate one or keep the existing one)")
+	return err
+}
+
+func (rcloneBackend) ReadNonInteractiveInput(a *AuthInfo) {
+	if rcloneCryptRemote {
+		a.rcloneCryptPassword = os.Getenv("LUMIO_RCLONE_CRYPT_PASSWORD")
+		a.rcloneCryptSalt = os.Getenv("LUMIO_RCLONE_CRYPT_SALT")
+	}
+}
+
+func (b rcloneBackend) Configure(s3auth AuthInfo, tmpDir string, rcloneSettings ToolSettings) (string, error) {
 	currentu, _ := user.Current()
 	rcloneConfigPath := strings.Replace(rcloneSettings.configPath, "~", currentu.HomeDir, 1)
 	tmpRcloneConfig := fmt.Sprintf("%s/temp_rclone.config", tmpDir)
-	info, err := util.UpdateConfig(getRcloneSetting(s3auth), rcloneConfigPath, tmpRcloneConfig, rcloneSettings.carefullUpdate, rcloneSettings.singleSection)
+	newConfig := getRcloneSetting(s3auth)
+	cryptRemoteName := getCryptRcloneRemoteName(s3auth.ProjectId)
+	generatedCryptSecrets := false
+	if rcloneCryptRemote {
+		var err error
+		generatedCryptSecrets, err = addRcloneCryptSetting(newConfig, s3auth, rcloneConfigPath)
+		if err != nil {
+			return "Failed generating rclone crypt remote", err
+		}
+	}
+	info, err := util.UpdateConfig(newConfig, rcloneConfigPath, tmpRcloneConfig, rcloneSettings.carefullUpdate, rcloneSettings.singleSection)
 	if err != nil {
 		return info, err
 	}
 	remoteName := getPrivateRcloneRemoteName(s3auth.ProjectId)
-	info, err = ValidateRemote(tmpRcloneConfig, remoteName, "rclone", ValidateRcloneRemote, rcloneSettings.ValidationDisabled)
+	info, err = ValidateRemote(tmpRcloneConfig, remoteName, "rclone", validatorFor(b, s3auth), rcloneSettings.ValidationDisabled)
 	if err != nil {
 		return info, err
 	}
+	if rcloneCryptRemote {
+		info, err = ValidateRemote(tmpRcloneConfig, cryptRemoteName, "rclone", validatorFor(b, s3auth), rcloneSettings.ValidationDisabled)
+		if err != nil {
+			return info, err
+		}
+	}
 	inf, err := util.CommitTempConfigFile(tmpRcloneConfig, rcloneConfigPath)
 
 	if err != nil {
@@ -62,9 +210,96 @@ func addRcloneRemotes(s3auth AuthInfo, tmpDir string, rcloneSettings ToolSetting
 
 	fmt.Printf("Updated rclone config %s\n\n", rcloneConfigPath)
 	fmt.Printf(passedRcloneRemoteValdidationMessage, remoteName, s3auth.ProjectId, getPublicRcloneRemoteName(s3auth.ProjectId), s3auth.ProjectId, s3auth.ProjectId)
+	if rcloneCryptRemote {
+		fmt.Printf(passedRcloneCryptRemoteValidationMessage, cryptRemoteName, newConfig[cryptRemoteName]["remote"])
+		if generatedCryptSecrets {
+			fmt.Printf(rcloneCryptSecretMessage, cryptRemoteName, rcloneConfigPath)
+		}
+	}
 	return "", nil
 }
 
+// Adds the crypt remote to the new settings. An existing password and salt are never
+// replaced unless given by the user, as that would make the encrypted data unreadable
+// Returns true if a new password and salt were generated
+func addRcloneCryptSetting(rcloneSettings map[string]map[string]string, a AuthInfo, rcloneConfigPath string) (bool, error) {
+	cryptRemoteName := getCryptRcloneRemoteName(a.ProjectId)
+	cryptSettings := map[string]string{
+		"type":       "crypt",
+		"remote":     fmt.Sprintf("%s:%s", getPrivateRcloneRemoteName(a.ProjectId), getBucketName(a, "crypt")),
+		"project_id": fmt.Sprintf("%d", a.ProjectId)}
+	rcloneSettings[cryptRemoteName] = cryptSettings
+
+	cryptRemoteExists := false
+	if util.CheckFileExists(rcloneConfigPath) {
+		cfg, err := ini.Load(rcloneConfigPath)
+		if err != nil {
+			return false, err
+		}
+		cryptRemoteExists = cfg.HasSection(cryptRemoteName) && cfg.Section(cryptRemoteName).HasKey("password")
+	}
+	password := a.rcloneCryptPassword
+	salt := a.rcloneCryptSalt
+	if password == "" && salt != "" {
+		return false, errors.New("a salt for the rclone crypt remote was given without a password")
+	}
+	generated := false
+	if password == "" {
+		if cryptRemoteExists {
+			fmt.Printf("Keeping the existing password for the rclone crypt remote %s\n", cryptRemoteName)
+			return false, nil
+		}
+		var err error
+		password, err = util.GenerateSecret(32)
+		if err != nil {
+			return false, err
+		}
+		generated = true
+	}
+	if salt == "" && !cryptRemoteExists {
+		var err error
+		salt, err = util.GenerateSecret(32)
+		if err != nil {
+			return false, err
+		}
+		generated = true
+	}
+	var err error
+	cryptSettings["password"], err = obscureRclonePassword(password)
+	if err != nil {
+		return false, err
+	}
+	if salt != "" {
+		cryptSettings["password2"], err = obscureRclonePassword(salt)
+		if err != nil {
+			return false, err
+		}
+	}
+	return generated, nil
+}
+
+// Crypt remotes wrapping a deleted remote are deleted as well
+func (rcloneBackend) Delete(configPath string, sectionNames []string) error {
+	toDelete := append([]string{}, sectionNames...)
+	if util.CheckFileExists(configPath) {
+		cfg, err := ini.Load(configPath)
+		if err != nil {
+			return err
+		}
+		for _, section := range cfg.Sections() {
+			if section.Key("type").String() != "crypt" || util.StringInSlice(section.Name(), toDelete) {
+				continue
+			}
+			wrapped, _, _ := strings.Cut(section.Key("remote").String(), ":")
+			if util.StringInSlice(wrapped, sectionNames) {
+				fmt.Printf("Also deleting crypt remote %s wrapping %s\n", section.Name(), wrapped)
+				toDelete = append(toDelete, section.Name())
+			}
+		}
+	}
+	return util.DeleteIniSectionsFromFile(configPath, toDelete)
+}
+
 func getRcloneSetting(a AuthInfo) map[string]map[string]string {
 	rcloneSettings := make(map[string]map[string]string)
 	privateRemoteName := getPrivateRcloneRemoteName(a.ProjectId)
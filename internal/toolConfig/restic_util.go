@@ -0,0 +1,185 @@
+package toolConfig
+
+import (
+	"bufio"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"strings"
+)
+
+const passedResticValidationMessage = `Created restic environment %s for project_%d
+	Repository: %s
+	Load it with: source %s
+`
+const resticPasswordMessage = `Generated a new restic repository password in %s
+	IMPORTANT: Keep a copy of this file, data in the repository can not be recovered without it
+`
+const resticNoRepositoryMessage = `No restic repository exists yet at %s
+	Initialize it with restic init after loading the environment
+`
+
+func getResticRepository(a AuthInfo) string {
+	return fmt.Sprintf("s3:%s/%s", a.Url, getBucketName(a, "restic"))
+}
+
+func getResticSetting(a AuthInfo, passwordFilePath string) []string {
+	return []string{
+		fmt.Sprintf("# restic repository for project_%d, generated by lumio-conf", a.ProjectId),
+		fmt.Sprintf("export RESTIC_REPOSITORY=%s", quoteSh(getResticRepository(a))),
+		fmt.Sprintf("export RESTIC_PASSWORD_FILE=%s", quoteSh(passwordFilePath)),
+		fmt.Sprintf("export AWS_ACCESS_KEY_ID=%s", quoteSh(a.s3AccessKey)),
+		fmt.Sprintf("export AWS_SECRET_ACCESS_KEY=%s", quoteSh(a.s3SecretKey)),
+		fmt.Sprintf("export LUMI_PROJECT_ID='%d'", a.ProjectId)}
+}
+
+// Read the variables from an environment file written by lumio-conf
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !found {
+			continue
+		}
+		vars[strings.TrimSpace(k)] = unquoteSh(strings.TrimSpace(v))
+	}
+	return vars, scanner.Err()
+}
+
+// Reverses quoteSh
+func unquoteSh(value string) string {
+	if len(value) < 2 || !strings.HasPrefix(value, "'") || !strings.HasSuffix(value, "'") {
+		return value
+	}
+	return strings.ReplaceAll(value[1:len(value)-1], `'\''`, "'")
+}
+
+func getResticPasswordFilePath(envFilePath string) string {
+	return strings.TrimSuffix(envFilePath, ".env") + ".password"
+}
+
+func ValidateResticRepository(resticEnvFilePath string, remoteName string) error {
+	vars, err := readEnvFile(resticEnvFilePath)
+	if err != nil {
+		return err
+	}
+	// restic gives the same hint for wrong keys as for a missing repository
+	repository := strings.TrimPrefix(vars["RESTIC_REPOSITORY"], "s3:")
+	url := repository[:max(strings.LastIndex(repository, "/"), 0)]
+	err = util.CheckS3Credentials(url, vars["AWS_ACCESS_KEY_ID"], vars["AWS_SECRET_ACCESS_KEY"])
+	if err != nil {
+		return err
+	}
+	var env []string
+	for k, v := range vars {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	// The password file next to the temporary config has not been commited yet
+	env = append(env, fmt.Sprintf("RESTIC_PASSWORD_FILE=%s", getResticPasswordFilePath(resticEnvFilePath)))
+	err = util.CheckCommandEnv(env, "restic", "--no-lock", "cat", "config")
+	// Valid credentials but the repository has not been initialized
+	if err != nil && strings.Contains(err.Error(), "repository does not exist") {
+		fmt.Printf(resticNoRepositoryMessage, vars["RESTIC_REPOSITORY"])
+		return nil
+	}
+	return err
+}
+
+type resticBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&ResticSettings, resticBackend{})
+}
+
+func (resticBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateResticRepository(configPath, remoteName)
+}
+
+func (b resticBackend) Configure(s3auth AuthInfo, tmpDir string, resticSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	resticConfigDir := strings.Replace(resticSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	resticEnvPath := getEndpointFilePath(resticConfigDir, remoteName, ".env")
+	resticPasswordPath := getResticPasswordFilePath(resticEnvPath)
+	tmpResticEnv := fmt.Sprintf("%s/temp_restic.env", tmpDir)
+	tmpResticPassword := getResticPasswordFilePath(tmpResticEnv)
+
+	// Never replace an existing password, it would make the repository unreadable
+	newPassword := !util.CheckFileExists(resticPasswordPath)
+	if newPassword {
+		password, err := util.GenerateSecret(32)
+		if err != nil {
+			return "Failed generating restic password", err
+		}
+		err = os.WriteFile(tmpResticPassword, []byte(password+"\n"), 0600)
+		if err != nil {
+			return "Failed writing temporary restic password file", err
+		}
+	} else {
+		inf, err := util.CommitTempConfigFile(resticPasswordPath, tmpResticPassword)
+		if err != nil {
+			return inf, err
+		}
+	}
+	err := os.WriteFile(tmpResticEnv, []byte(strings.Join(getResticSetting(s3auth, resticPasswordPath), "\n")+"\n"), 0600)
+	if err != nil {
+		return "Failed writing temporary restic environment file", err
+	}
+
+	info, err := ValidateRemote(tmpResticEnv, remoteName, "restic", validatorFor(b, s3auth), resticSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	if newPassword {
+		inf, err := util.CommitTempConfigFile(tmpResticPassword, resticPasswordPath)
+		if err != nil {
+			return fmt.Sprintf("While saving restic password, %s", inf), err
+		}
+	}
+	inf, err := util.CommitTempConfigFile(tmpResticEnv, resticEnvPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated restic environment %s\n\n", resticEnvPath)
+	if newPassword {
+		fmt.Printf(resticPasswordMessage, resticPasswordPath)
+	}
+	fmt.Printf(passedResticValidationMessage, remoteName, s3auth.ProjectId, getResticRepository(s3auth), resticEnvPath)
+	return "", nil
+}
+
+func (resticBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		envFile := getEndpointFilePath(configDir, sectionName, ".env")
+		if !util.CheckFileExists(envFile) {
+			fmt.Printf("WARNING: While deleting restic endpoint %s, no such file %s\n", sectionName, envFile)
+			continue
+		}
+		err := os.Remove(envFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted restic environment %s\n", envFile)
+		passwordFile := getResticPasswordFilePath(envFile)
+		if util.CheckFileExists(passwordFile) {
+			fmt.Printf("WARNING: Kept the repository password %s, remove it manually if the repository is no longer needed\n", passwordFile)
+		}
+	}
+	return nil
+}
+
+func (resticBackend) ListEndpoints(configDir string) ([]string, error) {
+	return listEndpointFiles(configDir, ".env")
+}
@@ -27,9 +27,9 @@ func ValidateS3cmdRemote(s3cmdConfigFilePath string, remoteName string) error {
 
 func deleteExtraS3cmdConfig(configFile string, projectNames []string) error {
 	currentu, _ := user.Current()
-	if configFile == systemDefaultConfigPaths["s3cmd"] {
+	configFullPath := strings.Replace(systemDefaultConfigPaths["s3cmd"], "~", currentu.HomeDir, 1)
+	if configFile == configFullPath {
 		for _, projectName := range projectNames {
-			configFullPath := strings.Replace(configFile, "~", currentu.HomeDir, 1)
 			extraConfig := fmt.Sprintf("%s-%s", configFullPath, projectName)
 			if util.CheckFileExists(extraConfig) {
 				fmt.Printf("Removing profile %s by deleting the file %s\n", projectName, extraConfig)
@@ -62,7 +62,31 @@ func getS3cmdSetting(a AuthInfo) map[string]map[string]string {
 
 }
 
-func adds3cmdRemote(s3auth AuthInfo, tmpDir string, s3cmdSettings ToolSettings) (string, error) {
+type s3cmdBackend struct {
+	baseBackend
+	iniSections
+}
+
+func init() {
+	registerTool(&S3cmdSettings, s3cmdBackend{})
+}
+
+func (s3cmdBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateS3cmdRemote(configPath, remoteName)
+}
+
+func (s3cmdBackend) NeedsChunksize() bool {
+	return true
+}
+
+func (s3cmdBackend) CheckArguments(s3cmdSettings ToolSettings) error {
+	if s3cmdSettings.NoReplace && s3cmdSettings.configPath != systemDefaultConfigPaths["s3cmd"] {
+		fmt.Printf("WARNING: Using --keep-default s3cmd together with --s3cmd-config has no effect\n")
+	}
+	return nil
+}
+
+func (b s3cmdBackend) Configure(s3auth AuthInfo, tmpDir string, s3cmdSettings ToolSettings) (string, error) {
 
 	currentu, _ := user.Current()
 	s3cmdBaseConfigPath := strings.Replace(s3cmdSettings.configPath, "~", currentu.HomeDir, 1)
@@ -74,7 +98,7 @@ func adds3cmdRemote(s3auth AuthInfo, tmpDir string, s3cmdSettings ToolSettings)
 	if err != nil {
 		return info, err
 	}
-	info, err = ValidateRemote(tmps3cmdConfig, remoteName, "s3cmd", ValidateS3cmdRemote, s3cmdSettings.ValidationDisabled)
+	info, err = ValidateRemote(tmps3cmdConfig, remoteName, "s3cmd", validatorFor(b, s3auth), s3cmdSettings.ValidationDisabled)
 	if err != nil {
 		return info, err
 	}
@@ -118,3 +142,11 @@ func adds3cmdRemote(s3auth AuthInfo, tmpDir string, s3cmdSettings ToolSettings)
 	return "", nil
 
 }
+
+func (s3cmdBackend) Delete(configPath string, sectionNames []string) error {
+	err := util.DeleteIniSectionsFromFile(configPath, sectionNames)
+	if err != nil {
+		return err
+	}
+	return deleteExtraS3cmdConfig(configPath, sectionNames)
+}
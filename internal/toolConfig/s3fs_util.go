@@ -0,0 +1,152 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"strings"
+)
+
+const passedS3fsRemoteValidationMessage = `Added s3fs credentials %s for project_%d
+	Mount the bucket with
+	mkdir -p %s && %s
+`
+
+func getS3fsEntry(a AuthInfo) string {
+	// Without a bucket the entry is used for all buckets
+	if a.Bucket == "" {
+		return fmt.Sprintf("%s:%s", a.s3AccessKey, a.s3SecretKey)
+	}
+	return fmt.Sprintf("%s:%s:%s", a.Bucket, a.s3AccessKey, a.s3SecretKey)
+}
+
+func isDefaultS3fsEntry(entry string) bool {
+	return strings.Count(strings.TrimSpace(entry), ":") == 1
+}
+
+// Bucket of a password file entry, empty for the default entry
+func getS3fsEntryBucket(entry string) string {
+	if isDefaultS3fsEntry(entry) {
+		return ""
+	}
+	bucket, _, _ := strings.Cut(strings.TrimSpace(entry), ":")
+	return bucket
+}
+
+func describeS3fsEntry(entry string) string {
+	if bucket := getS3fsEntryBucket(entry); bucket != "" {
+		return fmt.Sprintf("bucket %s", bucket)
+	}
+	return "all buckets"
+}
+
+// s3fs refuses a password file with more than one default entry, and only
+// uses the first entry of a bucket. Entries of other endpoints are replaced,
+// entries not written by us have to be removed by the user
+func replaceDuplicateS3fsEntries(newEntries map[string]string, remoteName string, s3fsConfigPath string) error {
+	bucket := getS3fsEntryBucket(newEntries[remoteName])
+	existing, err := util.ReadConfigBlocks(s3fsConfigPath, "#")
+	if err != nil {
+		return err
+	}
+	for name, entry := range existing {
+		if name != remoteName && strings.TrimSpace(entry) != "" && getS3fsEntryBucket(entry) == bucket {
+			fmt.Printf("WARNING: Replacing the s3fs credentials of %s for %s\n", name, describeS3fsEntry(entry))
+			newEntries[name] = ""
+		}
+	}
+	lines, err := util.ReadLinesOutsideBlocks(s3fsConfigPath, "#")
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || !strings.Contains(line, ":") {
+			continue
+		}
+		if getS3fsEntryBucket(line) == bucket {
+			return errors.New(fmt.Sprintf("%s already has s3fs credentials for %s which were not added by lumio-conf, remove them or use --bucket", s3fsConfigPath, describeS3fsEntry(line)))
+		}
+	}
+	return nil
+}
+
+func getS3fsMountCommand(a AuthInfo, passwdFilePath string) (string, string) {
+	bucket := a.Bucket
+	if bucket == "" {
+		bucket = "<bucket_name>"
+	}
+	mountPoint := fmt.Sprintf("~/lumio/%s", bucket)
+	return mountPoint, fmt.Sprintf("s3fs %s %s -o passwd_file=%s -o url=%s -o use_path_request_style -o sigv2",
+		bucket, mountPoint, passwdFilePath, a.Url)
+}
+
+// s3fs can not check the credentials without mounting, do the request ourselves
+func ValidateS3fsRemote(s3fsPasswdFilePath string, remoteName string, url string) error {
+	blocks, err := util.ReadConfigBlocks(s3fsPasswdFilePath, "#")
+	if err != nil {
+		return err
+	}
+	fields := strings.Split(strings.TrimSpace(blocks[remoteName]), ":")
+	if len(fields) < 2 {
+		return errors.New(fmt.Sprintf("no valid s3fs credentials for %s in %s", remoteName, s3fsPasswdFilePath))
+	}
+	return util.CheckS3Credentials(url, fields[len(fields)-2], fields[len(fields)-1])
+}
+
+type s3fsBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&S3fsSettings, s3fsBackend{})
+}
+
+func (s3fsBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateS3fsRemote(configPath, remoteName, s3auth.Url)
+}
+
+func (b s3fsBackend) Configure(s3auth AuthInfo, tmpDir string, s3fsSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	s3fsConfigPath := strings.Replace(s3fsSettings.configPath, "~", currentu.HomeDir, 1)
+	tmpS3fsConfig := fmt.Sprintf("%s/temp_s3fs.passwd", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	newEntries := map[string]string{remoteName: getS3fsEntry(s3auth)}
+
+	if util.CheckFileExists(s3fsConfigPath) {
+		err := replaceDuplicateS3fsEntries(newEntries, remoteName, s3fsConfigPath)
+		if err != nil {
+			return fmt.Sprintf("Failed checking the existing entries of %s", s3fsConfigPath), err
+		}
+	}
+	info, err := util.UpdateBlockConfig(newEntries, "#", s3fsConfigPath, tmpS3fsConfig)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpS3fsConfig, remoteName, "s3fs", validatorFor(b, s3auth), s3fsSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpS3fsConfig, s3fsConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+	// s3fs does not accept password files readable by others
+	err = os.Chmod(s3fsConfigPath, 0600)
+	if err != nil {
+		return fmt.Sprintf("failed chmod on %s", s3fsConfigPath), err
+	}
+
+	fmt.Printf("Updated s3fs password file %s\n\n", s3fsConfigPath)
+	mountPoint, mountCommand := getS3fsMountCommand(s3auth, s3fsConfigPath)
+	fmt.Printf(passedS3fsRemoteValidationMessage, remoteName, s3auth.ProjectId, mountPoint, mountCommand)
+	return "", nil
+}
+
+func (s3fsBackend) Delete(configPath string, sectionNames []string) error {
+	return util.DeleteConfigBlocksFromFile(configPath, "#", sectionNames)
+}
+
+func (s3fsBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListConfigBlocks(configPath, "#")
+}
@@ -0,0 +1,132 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedS5cmdRemoteValidationMessage = `Created s5cmd profile %s for project_%d
+	Use the generated wrapper which sets the endpoint, profile and part size
+	%s COMMAND ARGS
+`
+
+// s5cmd has no config file for the endpoint and part size
+// so these are set by a small wrapper script
+const s5cmdWrapperScript = `#!/bin/sh
+# s5cmd wrapper for project_%d, generated by lumio-conf
+case "$1" in
+cp | mv | pipe | sync)
+	cmd="$1"
+	shift
+	set -- "$cmd" --part-size %d "$@"
+	;;
+esac
+exec s5cmd --credentials-file %s --profile %s --endpoint-url %s "$@"
+`
+
+func getS5cmdWrapperPath(credentialsFilePath string, remoteName string) string {
+	return filepath.Join(filepath.Dir(credentialsFilePath), fmt.Sprintf("s5cmd-%s", remoteName))
+}
+
+func ValidateS5cmdRemote(s5cmdCredentialsFilePath string, remoteName string, url string) error {
+	return util.CheckCommand("s5cmd",
+		"--credentials-file", s5cmdCredentialsFilePath,
+		"--profile", remoteName,
+		"--endpoint-url", url,
+		"--retry-count", "1",
+		"ls")
+}
+
+func getS5cmdSetting(a AuthInfo) map[string]map[string]string {
+	s5cmdSettings := make(map[string]map[string]string)
+	s5cmdSettings[getGenericRemoteName(a.ProjectId)] = map[string]string{
+		"aws_access_key_id":     a.s3AccessKey,
+		"aws_secret_access_key": a.s3SecretKey,
+		"project_id":            fmt.Sprintf("%d", a.ProjectId)}
+	return s5cmdSettings
+}
+
+type s5cmdBackend struct {
+	baseBackend
+	iniSections
+}
+
+func init() {
+	registerTool(&S5cmdSettings, s5cmdBackend{})
+}
+
+func (s5cmdBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateS5cmdRemote(configPath, remoteName, s3auth.Url)
+}
+
+func (s5cmdBackend) NeedsChunksize() bool {
+	return true
+}
+
+func (b s5cmdBackend) Configure(s3auth AuthInfo, tmpDir string, s5cmdSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	s5cmdConfigPath := strings.Replace(s5cmdSettings.configPath, "~", currentu.HomeDir, 1)
+	tmpS5cmdConfig := fmt.Sprintf("%s/temp_s5cmd.config", tmpDir)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	newConfig := getS5cmdSetting(s3auth)
+	if !s5cmdSettings.NoReplace {
+		newConfig["default"] = util.MergeMaps(newConfig[remoteName], map[string]string{"original_name": remoteName})
+	}
+	info, err := util.UpdateConfig(newConfig, s5cmdConfigPath, tmpS5cmdConfig, s5cmdSettings.carefullUpdate, s5cmdSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpS5cmdConfig, remoteName, "s5cmd", validatorFor(b, s3auth), s5cmdSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+
+	tmpWrapper := getS5cmdWrapperPath(tmpS5cmdConfig, remoteName)
+	wrapper := fmt.Sprintf(s5cmdWrapperScript, s3auth.ProjectId, s3auth.Chunksize, quoteSh(s5cmdConfigPath), quoteSh(remoteName), quoteSh(s3auth.Url))
+	err = os.WriteFile(tmpWrapper, []byte(wrapper), 0700)
+	if err != nil {
+		return "Failed writing temporary s5cmd wrapper", err
+	}
+	inf, err := util.CommitTempConfigFile(tmpS5cmdConfig, s5cmdConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+	wrapperPath := getS5cmdWrapperPath(s5cmdConfigPath, remoteName)
+	inf, err = util.CommitTempConfigFile(tmpWrapper, wrapperPath)
+	if err != nil {
+		return fmt.Sprintf("While creating s5cmd wrapper, %s", inf), err
+	}
+	err = os.Chmod(wrapperPath, 0700)
+	if err != nil {
+		return fmt.Sprintf("failed chmod on %s", wrapperPath), err
+	}
+
+	fmt.Printf("Updated s5cmd config %s\n\n", s5cmdConfigPath)
+	if !s5cmdSettings.NoReplace {
+		fmt.Printf("New profile set as default\n")
+	}
+	fmt.Printf(passedS5cmdRemoteValidationMessage, remoteName, s3auth.ProjectId, wrapperPath)
+	return "", nil
+}
+
+func (s5cmdBackend) Delete(configPath string, sectionNames []string) error {
+	err := util.DeleteIniSectionsFromFile(configPath, sectionNames)
+	if err != nil {
+		return err
+	}
+	for _, sectionName := range sectionNames {
+		wrapperPath := getS5cmdWrapperPath(configPath, sectionName)
+		if util.CheckFileExists(wrapperPath) {
+			fmt.Printf("Removing s5cmd wrapper %s\n", wrapperPath)
+			err = os.Remove(wrapperPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedSnakemakeRemoteValidationMessage = `Created Snakemake profile %s for project_%d
+	The keys are read from the environment, load them before running snakemake
+	source %s && snakemake --profile %s
+`
+
+const snakemakeEnvFileName = "storage-s3.env"
+
+func getSnakemakeSetting(a AuthInfo) map[string]any {
+	snakemakeSettings := map[string]any{
+		"default-storage-provider": "s3",
+		"storage-s3-endpoint-url":  a.Url}
+	if a.Bucket != "" {
+		snakemakeSettings["default-storage-prefix"] = fmt.Sprintf("s3://%s", a.Bucket)
+	}
+	return snakemakeSettings
+}
+
+// The s3 storage plugin reads the keys from these if not given on the commandline
+func getSnakemakeEnv(a AuthInfo) []string {
+	return []string{
+		fmt.Sprintf("# Snakemake storage keys for project_%d, generated by lumio-conf", a.ProjectId),
+		fmt.Sprintf("export SNAKEMAKE_STORAGE_S3_ACCESS_KEY=%s", quoteSh(a.s3AccessKey)),
+		fmt.Sprintf("export SNAKEMAKE_STORAGE_S3_SECRET_KEY=%s", quoteSh(a.s3SecretKey))}
+}
+
+// Snakemake needs a workflow for a dry run, so only the keys in the env file are checked
+func ValidateSnakemakeProfile(snakemakeConfigFilePath string, url string) error {
+	vars, err := readEnvFile(filepath.Join(filepath.Dir(snakemakeConfigFilePath), snakemakeEnvFileName))
+	if err != nil {
+		return err
+	}
+	return util.CheckS3Credentials(url, vars["SNAKEMAKE_STORAGE_S3_ACCESS_KEY"], vars["SNAKEMAKE_STORAGE_S3_SECRET_KEY"])
+}
+
+type snakemakeBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&SnakemakeSettings, snakemakeBackend{})
+}
+
+func (snakemakeBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateSnakemakeProfile(configPath, s3auth.Url)
+}
+
+func (b snakemakeBackend) Configure(s3auth AuthInfo, tmpDir string, snakemakeSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	profileDir := getEndpointFilePath(strings.Replace(snakemakeSettings.configPath, "~", currentu.HomeDir, 1), remoteName, "")
+	snakemakeConfigPath := filepath.Join(profileDir, "config.yaml")
+	snakemakeEnvPath := filepath.Join(profileDir, snakemakeEnvFileName)
+	tmpProfileDir := filepath.Join(tmpDir, "snakemake", remoteName)
+	err := os.MkdirAll(tmpProfileDir, 0700)
+	if err != nil {
+		return "Failed creating temporary snakemake profile", err
+	}
+	tmpSnakemakeConfig := filepath.Join(tmpProfileDir, "config.yaml")
+	tmpSnakemakeEnv := filepath.Join(tmpProfileDir, snakemakeEnvFileName)
+
+	info, err := util.UpdateYamlKeys(getSnakemakeSetting(s3auth), snakemakeConfigPath, tmpSnakemakeConfig)
+	if err != nil {
+		return info, err
+	}
+	err = util.SetYamlHeadComment(tmpSnakemakeConfig, fmt.Sprintf("Storage keys for project_%d are in %s, source it before running snakemake", s3auth.ProjectId, snakemakeEnvPath))
+	if err != nil {
+		return "Failed updating snakemake profile", err
+	}
+	err = os.WriteFile(tmpSnakemakeEnv, []byte(strings.Join(getSnakemakeEnv(s3auth), "\n")+"\n"), 0600)
+	if err != nil {
+		return "Failed writing temporary snakemake environment file", err
+	}
+	info, err = ValidateRemote(tmpSnakemakeConfig, remoteName, "snakemake", validatorFor(b, s3auth), snakemakeSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpSnakemakeEnv, snakemakeEnvPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+	inf, err = util.CommitTempConfigFile(tmpSnakemakeConfig, snakemakeConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated Snakemake profile %s\n\n", profileDir)
+	fmt.Printf(passedSnakemakeRemoteValidationMessage, remoteName, s3auth.ProjectId, snakemakeEnvPath, profileDir)
+	return "", nil
+}
+
+// Removes the files written by us, and the profile if nothing else is left
+func (snakemakeBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		profileDir := getEndpointFilePath(configDir, sectionName, "")
+		if !util.IsDirectory(profileDir) {
+			fmt.Printf("WARNING: While deleting Snakemake profile %s, no such directory %s\n", sectionName, profileDir)
+			continue
+		}
+		for _, file := range []string{"config.yaml", snakemakeEnvFileName} {
+			err := os.Remove(filepath.Join(profileDir, file))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		err := os.Remove(profileDir)
+		if err != nil {
+			fmt.Printf("WARNING: Removed the lumio-conf files from %s, other files were kept\n", profileDir)
+		} else {
+			fmt.Printf("Deleted Snakemake profile %s\n", profileDir)
+		}
+	}
+	return nil
+}
+
+// Profiles created by us are recognized by the environment file
+func (snakemakeBackend) ListEndpoints(configDir string) ([]string, error) {
+	envFiles, err := filepath.Glob(filepath.Join(configDir, "*", snakemakeEnvFileName))
+	if err != nil {
+		return nil, err
+	}
+	var remoteNames []string
+	for _, envFile := range envFiles {
+		remoteNames = append(remoteNames, filepath.Base(filepath.Dir(envFile)))
+	}
+	return remoteNames, nil
+}
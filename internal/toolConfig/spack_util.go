@@ -0,0 +1,103 @@
+package toolConfig
+
+import (
+	"errors"
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedSpackMirrorValidationMessage = `Created Spack mirror %s for project_%d using %s
+	Build caches are stored in %s
+	spack buildcache push %s <spec>
+`
+
+func getSpackSetting(a AuthInfo) map[string]map[string]any {
+	remoteName := getGenericRemoteName(a.ProjectId)
+	mirrorSettings := map[string]any{
+		"url":          fmt.Sprintf("s3://%s", getBucketName(a, "spack")),
+		"endpoint_url": a.Url}
+	// Spack uses boto3, which only finds profiles in the default location
+	awsCredentialsPath, hasProfile := getExistingAwsProfile(remoteName)
+	currentu, _ := user.Current()
+	if hasProfile && awsCredentialsPath == strings.Replace(systemDefaultConfigPaths["aws"], "~", currentu.HomeDir, 1) {
+		mirrorSettings["profile"] = remoteName
+	} else {
+		mirrorSettings["access_pair"] = []string{a.s3AccessKey, a.s3SecretKey}
+	}
+	return map[string]map[string]any{remoteName: mirrorSettings}
+}
+
+// Spack is given the directory of the temporary config as an extra configuration scope
+func ValidateSpackMirror(spackConfigFilePath string, remoteName string, a AuthInfo) error {
+	if _, err := exec.LookPath("spack"); err == nil {
+		scope := filepath.Dir(spackConfigFilePath)
+		mirrors, err := util.CommandOutput("spack", "-C", scope, "mirror", "list")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(mirrors, remoteName) {
+			return errors.New(fmt.Sprintf("mirror %s missing from spack mirror list", remoteName))
+		}
+		err = util.CheckCommand("spack", "-C", scope, "buildcache", "list")
+		if err != nil {
+			return err
+		}
+	}
+	return util.CheckS3Credentials(a.Url, a.s3AccessKey, a.s3SecretKey)
+}
+
+type spackBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&SpackSettings, spackBackend{})
+}
+
+func (spackBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateSpackMirror(configPath, remoteName, s3auth)
+}
+
+func (b spackBackend) Configure(s3auth AuthInfo, tmpDir string, spackSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	spackConfigPath := strings.Replace(spackSettings.configPath, "~", currentu.HomeDir, 1)
+	// Spack reads configuration scopes from a directory, the file name is fixed
+	tmpSpackConfig := fmt.Sprintf("%s/spack/mirrors.yaml", tmpDir)
+	err := os.MkdirAll(filepath.Dir(tmpSpackConfig), 0700)
+	if err != nil {
+		return "Failed creating temporary spack config directory", err
+	}
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	newConfig := getSpackSetting(s3auth)
+	info, err := util.UpdateYamlConfig(newConfig, "mirrors", spackConfigPath, tmpSpackConfig, spackSettings.carefullUpdate, spackSettings.singleSection)
+	if err != nil {
+		return info, err
+	}
+	info, err = ValidateRemote(tmpSpackConfig, remoteName, "spack", validatorFor(b, s3auth), spackSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpSpackConfig, spackConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	credentials := "the keys stored in the mirror config"
+	if profile, found := newConfig[remoteName]["profile"]; found {
+		credentials = fmt.Sprintf("the aws profile %s", profile)
+	}
+	fmt.Printf("Updated Spack mirrors %s\n\n", spackConfigPath)
+	fmt.Printf(passedSpackMirrorValidationMessage, remoteName, s3auth.ProjectId, credentials, newConfig[remoteName]["url"], remoteName)
+	return "", nil
+}
+
+func (spackBackend) Delete(configPath string, sectionNames []string) error {
+	return util.DeleteYamlSectionsFromFile(configPath, "mirrors", sectionNames)
+}
+
+func (spackBackend) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListYamlSections(configPath, "mirrors")
+}
@@ -0,0 +1,181 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const passedSystemdValidationMessage = `Created systemd user unit %s for project_%d
+	Nothing has been enabled, start the mount now and at every login with:
+	systemctl --user daemon-reload
+	systemctl --user enable --now %s
+`
+const systemdNoRemoteMessage = `WARNING: rclone remote %s not found in %s, the mount will fail without it
+	Create it with: lumio-conf --configure-only rclone
+`
+
+const systemdUnitTemplate = `# rclone mount of LUMI-O project_%d, generated by lumio-conf
+[Unit]
+Description=rclone mount of %s: (LUMI-O project_%d) on %s
+Documentation=man:rclone(1)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStartPre=/usr/bin/mkdir -p %s
+ExecStart=%s mount %s: %s \
+	--config %s \
+	--vfs-cache-mode writes \
+	--vfs-cache-max-size 10G \
+	--vfs-cache-max-age 24h \
+	--dir-cache-time 5m \
+	--umask 077
+ExecStop=%s -u %s
+Restart=on-failure
+RestartSec=10
+
+[Install]
+WantedBy=default.target
+`
+
+func getSystemdUnitName(remoteName string) string {
+	return fmt.Sprintf("rclone-mount-%s.service", remoteName)
+}
+
+// systemd needs absolute paths for the commands
+func getAbsoluteCommandPath(fallback string, commands ...string) string {
+	for _, command := range commands {
+		if path, err := exec.LookPath(command); err == nil {
+			if absPath, err := filepath.Abs(path); err == nil {
+				return absPath
+			}
+		}
+	}
+	return fallback
+}
+
+func getSystemdMountPoint(projectId int) string {
+	currentu, _ := user.Current()
+	return filepath.Join(currentu.HomeDir, "lumio", fmt.Sprintf("%d", projectId))
+}
+
+func getSystemdSetting(a AuthInfo) string {
+	currentu, _ := user.Current()
+	remoteName := getPrivateRcloneRemoteName(a.ProjectId)
+	mountPoint := getSystemdMountPoint(a.ProjectId)
+	return fmt.Sprintf(systemdUnitTemplate, a.ProjectId,
+		remoteName, a.ProjectId, mountPoint,
+		mountPoint,
+		getAbsoluteCommandPath("/usr/bin/rclone", "rclone"), remoteName, mountPoint,
+		strings.Replace(RcloneSettings.configPath, "~", currentu.HomeDir, 1),
+		getAbsoluteCommandPath("/usr/bin/fusermount", "fusermount3", "fusermount"), mountPoint)
+}
+
+func hasRcloneRemote(rcloneConfigPath string, remoteName string) bool {
+	if !util.CheckFileExists(rcloneConfigPath) {
+		return false
+	}
+	cfg, err := ini.Load(rcloneConfigPath)
+	return err == nil && cfg.HasSection(remoteName)
+}
+
+func ValidateSystemdUnit(unitFilePath string, remoteName string) error {
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		fmt.Printf("systemd-analyze not found, skipping validation of the unit\n")
+		return nil
+	}
+	// --user needs a running user manager, the unit is checked the same way without it
+	return util.CheckCommand("systemd-analyze", "verify", "--man=no", unitFilePath)
+}
+
+type systemdBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&SystemdSettings, systemdBackend{})
+}
+
+func (systemdBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateSystemdUnit(configPath, remoteName)
+}
+
+func (b systemdBackend) Configure(s3auth AuthInfo, tmpDir string, systemdSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	unitDir := strings.Replace(systemdSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getPrivateRcloneRemoteName(s3auth.ProjectId)
+	unitName := getSystemdUnitName(remoteName)
+	unitPath := filepath.Join(unitDir, unitName)
+	// systemd-analyze needs the real unit name
+	tmpUnit := filepath.Join(tmpDir, "systemd", unitName)
+	err := os.MkdirAll(filepath.Dir(tmpUnit), 0700)
+	if err != nil {
+		return "Failed creating temporary systemd directory", err
+	}
+
+	err = os.WriteFile(tmpUnit, []byte(getSystemdSetting(s3auth)), 0600)
+	if err != nil {
+		return "Failed writing temporary systemd unit", err
+	}
+	info, err := ValidateRemote(tmpUnit, remoteName, "systemd", validatorFor(b, s3auth), systemdSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpUnit, unitPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated systemd user unit %s\n\n", unitPath)
+	rcloneConfigPath := strings.Replace(RcloneSettings.configPath, "~", currentu.HomeDir, 1)
+	if !hasRcloneRemote(rcloneConfigPath, remoteName) {
+		fmt.Printf(systemdNoRemoteMessage, remoteName, rcloneConfigPath)
+	}
+	fmt.Printf(passedSystemdValidationMessage, unitName, s3auth.ProjectId, unitName)
+	return "", nil
+}
+
+// The unit is created for the private rclone remote, deleting
+// either the remote or the generic endpoint name removes it
+func (systemdBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		deleted := false
+		for _, remoteName := range []string{sectionName, sectionName + "-private"} {
+			unitFile := filepath.Join(configDir, getSystemdUnitName(remoteName))
+			if !util.CheckFileExists(unitFile) {
+				continue
+			}
+			err := os.Remove(unitFile)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Deleted systemd user unit %s\n", unitFile)
+			fmt.Printf("WARNING: A running mount is not stopped, run: systemctl --user disable --now %s\n", getSystemdUnitName(remoteName))
+			deleted = true
+		}
+		if !deleted {
+			fmt.Printf("WARNING: While deleting systemd unit for %s, no such unit in %s\n", sectionName, configDir)
+		}
+	}
+	return nil
+}
+
+func (systemdBackend) ListEndpoints(configDir string) ([]string, error) {
+	units, err := listEndpointFiles(configDir, ".service")
+	if err != nil {
+		return nil, err
+	}
+	var remoteNames []string
+	for _, unit := range units {
+		if remoteName, found := strings.CutPrefix(unit, "rclone-mount-"); found {
+			remoteNames = append(remoteNames, remoteName)
+		}
+	}
+	return remoteNames, nil
+}
@@ -0,0 +1,145 @@
+package toolConfig
+
+import (
+	"fmt"
+	"lumioconf/internal/util"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const passedTerraformValidationMessage = `Created Terraform/OpenTofu backend config %s for project_%d
+	Credentials are read from the aws profile %s
+	Set the state key in the backend "s3" block of your configuration, then run:
+	terraform init -backend-config=%s
+`
+const terraformNoProfileMessage = `WARNING: No aws profile %s found, the backend config will not work without it
+	Create it with: lumio-conf --configure-only aws
+`
+const terraformNoBucketMessage = `The state bucket %s does not exist yet
+	Create it with: aws s3 mb s3://%s --profile %s
+`
+
+const terraformBackendTemplate = `# Terraform/OpenTofu s3 backend for project_%d, generated by lumio-conf
+bucket                      = "%s"
+region                      = "lumi"
+profile                     = "%s"
+shared_credentials_files    = ["%s"]
+endpoints                   = { s3 = "%s" }
+use_path_style              = true
+skip_credentials_validation = true
+skip_region_validation      = true
+skip_requesting_account_id  = true
+`
+
+// Only used for validation, the real key is set by the user
+const terraformValidationStateKey = "lumio-conf-validation.tfstate"
+
+func getTerraformSetting(a AuthInfo) string {
+	currentu, _ := user.Current()
+	awsCredentialsPath := strings.Replace(AwsSettings.configPath, "~", currentu.HomeDir, 1)
+	return fmt.Sprintf(terraformBackendTemplate, a.ProjectId, getBucketName(a, "terraform"), getGenericRemoteName(a.ProjectId), awsCredentialsPath, a.Url)
+}
+
+// OpenTofu is preferred if both are installed
+func getTerraformCommand() string {
+	for _, command := range []string{"tofu", "terraform"} {
+		if _, err := exec.LookPath(command); err == nil {
+			return command
+		}
+	}
+	return ""
+}
+
+// Runs init with the backend config in an empty scratch configuration,
+// without tofu or terraform or the aws profile only the keys are checked
+func ValidateTerraformBackend(backendConfigFilePath string, remoteName string, s3auth AuthInfo) error {
+	bucketName := getBucketName(s3auth, "terraform")
+	command := getTerraformCommand()
+	if command == "" {
+		fmt.Printf("Neither tofu nor terraform found, skipping the init check of the backend config and only checking the keys\n")
+		return util.CheckS3Credentials(s3auth.Url, s3auth.s3AccessKey, s3auth.s3SecretKey)
+	}
+	if _, hasProfile := getExistingAwsProfile(remoteName); !hasProfile {
+		fmt.Printf("No aws profile %s, skipping the %s init check of the backend config and only checking the keys\n", remoteName, command)
+		return util.CheckS3Credentials(s3auth.Url, s3auth.s3AccessKey, s3auth.s3SecretKey)
+	}
+	scratchDir := filepath.Join(filepath.Dir(backendConfigFilePath), "terraform-validation")
+	err := os.MkdirAll(scratchDir, 0700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(scratchDir, "main.tf"), []byte("terraform {\n  backend \"s3\" {}\n}\n"), 0600)
+	if err != nil {
+		return err
+	}
+	err = util.CheckCommand(command, fmt.Sprintf("-chdir=%s", scratchDir), "init", "-input=false", "-no-color", "-reconfigure",
+		fmt.Sprintf("-backend-config=%s", backendConfigFilePath),
+		fmt.Sprintf("-backend-config=key=%s", terraformValidationStateKey))
+	// Valid credentials but the bucket has not been created
+	if err != nil && (strings.Contains(err.Error(), "NoSuchBucket") || strings.Contains(err.Error(), "bucket does not exist")) {
+		fmt.Printf(terraformNoBucketMessage, bucketName, bucketName, remoteName)
+		return nil
+	}
+	return err
+}
+
+type terraformBackend struct{ baseBackend }
+
+func init() {
+	registerTool(&TerraformSettings, terraformBackend{})
+}
+
+func (terraformBackend) Validate(s3auth AuthInfo, configPath string, remoteName string) error {
+	return ValidateTerraformBackend(configPath, remoteName, s3auth)
+}
+
+func (b terraformBackend) Configure(s3auth AuthInfo, tmpDir string, terraformSettings ToolSettings) (string, error) {
+	currentu, _ := user.Current()
+	terraformConfigDir := strings.Replace(terraformSettings.configPath, "~", currentu.HomeDir, 1)
+	remoteName := getGenericRemoteName(s3auth.ProjectId)
+	terraformConfigPath := getEndpointFilePath(terraformConfigDir, remoteName, ".backend.hcl")
+	tmpTerraformConfig := fmt.Sprintf("%s/temp_backend.hcl", tmpDir)
+
+	err := os.WriteFile(tmpTerraformConfig, []byte(getTerraformSetting(s3auth)), 0600)
+	if err != nil {
+		return "Failed writing temporary backend config", err
+	}
+	info, err := ValidateRemote(tmpTerraformConfig, remoteName, "terraform", validatorFor(b, s3auth), terraformSettings.ValidationDisabled)
+	if err != nil {
+		return info, err
+	}
+	inf, err := util.CommitTempConfigFile(tmpTerraformConfig, terraformConfigPath)
+	if err != nil {
+		return fmt.Sprintf("While updating configuration, %s", inf), err
+	}
+
+	fmt.Printf("Updated Terraform/OpenTofu backend config %s\n\n", terraformConfigPath)
+	if _, hasProfile := getExistingAwsProfile(remoteName); !hasProfile {
+		fmt.Printf(terraformNoProfileMessage, remoteName)
+	}
+	fmt.Printf(passedTerraformValidationMessage, remoteName, s3auth.ProjectId, remoteName, terraformConfigPath)
+	return "", nil
+}
+
+func (terraformBackend) Delete(configDir string, sectionNames []string) error {
+	for _, sectionName := range sectionNames {
+		configFile := getEndpointFilePath(configDir, sectionName, ".backend.hcl")
+		if !util.CheckFileExists(configFile) {
+			fmt.Printf("WARNING: While deleting terraform endpoint %s, no such file %s\n", sectionName, configFile)
+			continue
+		}
+		err := os.Remove(configFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted Terraform/OpenTofu backend config %s\n", configFile)
+	}
+	return nil
+}
+
+func (terraformBackend) ListEndpoints(configDir string) ([]string, error) {
+	return listEndpointFiles(configDir, ".backend.hcl")
+}
@@ -1,11 +1,34 @@
 package toolConfig
 
+import (
+	"lumioconf/internal/util"
+	"strings"
+)
+
 type validationFunc func(string, string) error
 
 var systemDefaultConfigPaths = map[string]string{
-	"rclone": "~/.config/rclone/rclone.conf",
-	"s3cmd":  "~/.s3cfg",
-	"aws":    "~/.aws/credentials"}
+	"rclone":     "~/.config/rclone/rclone.conf",
+	"s3cmd":      "~/.s3cfg",
+	"aws":        "~/.aws/credentials",
+	"restic":     "~/.config/lumio-conf/restic",
+	"s5cmd":      "~/.config/lumio-conf/s5cmd/credentials",
+	"mc":         "~/.mc/config.json",
+	"s3fs":       "~/.passwd-s3fs",
+	"duckdb":     "~/.duckdb/lumio-secrets.sql",
+	"gdal":       "~/.gdal/gdalrc",
+	"hadoop":     "~/.config/lumio-conf/hadoop/core-site.xml",
+	"dvc":        "~/.config/dvc/config",
+	"nextflow":   "~/.nextflow/config",
+	"snakemake":  "~/.config/snakemake",
+	"fsspec":     "~/.config/fsspec/s3.json",
+	"kopia":      "~/.config/kopia",
+	"terraform":  "~/.config/lumio-conf/terraform",
+	"kubernetes": "~/.config/lumio-conf/kubernetes",
+	"modulefile": "~/modulefiles/lumio",
+	"cyberduck":  "~/.duck/bookmarks",
+	"systemd":    "~/.config/systemd/user",
+	"spack":      "~/.spack/mirrors.yaml"}
 
 const systemDefaultS3Url = "https://lumidata.eu"
 
@@ -29,14 +52,96 @@ Open the Key details view and based on that give following information`
 
 // Unused
 //type remoteNameFunc func(int) string
-type AddRemote func(s3auth AuthInfo, tmpDir string, toolsettings ToolSettings) (string, error)
+
+// Implemented by every tool, see registerTool
+type Backend interface {
+	// Creates, validates and commits the configuration for the project.
+	// Returns extra info for the user on failure
+	Configure(s3auth AuthInfo, tmpDir string, toolsettings ToolSettings) (string, error)
+	Validate(s3auth AuthInfo, configPath string, remoteName string) error
+	Delete(configPath string, sectionNames []string) error
+	ListEndpoints(configPath string) ([]string, error)
+	// Hint for the user about a failed configuration, empty if there is none
+	ExplainError(err error) string
+	// --chunksize is only checked if an enabled tool uses it
+	NeedsChunksize() bool
+}
+
+// Optional for backends needing more input than the keys
+type inputReader interface {
+	ReadUserInput(a *AuthInfo) error
+	// With --noninteractive the values are read from the environment
+	ReadNonInteractiveInput(a *AuthInfo)
+}
+
+// Optional for backends warning about or rejecting combinations of arguments
+type argumentChecker interface {
+	CheckArguments(toolSettings ToolSettings) error
+}
+
+// Defaults embedded by every built-in backend
+type baseBackend struct{}
+
+func (baseBackend) ExplainError(err error) string {
+	return ""
+}
+
+func (baseBackend) NeedsChunksize() bool {
+	return false
+}
+
+// Embedded by tools keeping each endpoint as a section of a single ini file
+type iniSections struct{}
+
+func (iniSections) Delete(configPath string, sectionNames []string) error {
+	return util.DeleteIniSectionsFromFile(configPath, sectionNames)
+}
+
+func (iniSections) ListEndpoints(configPath string) ([]string, error) {
+	return util.ListIniSections(configPath)
+}
+
+var toolRegistry = map[string]*ToolSettings{}
+
+// Called from init() in the file of each tool
+func registerTool(settings *ToolSettings, backend Backend) {
+	settings.backend = backend
+	toolRegistry[settings.Name] = settings
+}
+
+// All registered tools by name, including tool definitions and plugins found on PATH
+func Tools() map[string]*ToolSettings {
+	loadToolDefinitions()
+	discoverPlugins()
+	return toolRegistry
+}
+
+func (t ToolSettings) Configure(s3auth AuthInfo, tmpDir string) (string, error) {
+	return t.backend.Configure(s3auth, tmpDir, t)
+}
+
+// Commands checked for IsPresent, for messages
+func (t ToolSettings) Command() string {
+	if len(t.commands) == 0 {
+		return t.Name
+	}
+	return strings.Join(t.commands, " or ")
+}
+
+func (t ToolSettings) ExplainError(err error) string {
+	return t.backend.ExplainError(err)
+}
 
 type Settings struct {
 	Chunksize      int
 	ProjectId      int
 	NonInteractive bool
 	DeleteList     string
+	ListEndpoints  bool
 	Url            string
+	Bucket         string
+	EnvFormat      string
+	EnvFile        string
 	ShowVersion    bool
 }
 type AuthInfo struct {
@@ -45,10 +150,16 @@ type AuthInfo struct {
 	ProjectId   int
 	Chunksize   int
 	Url         string
+	Bucket      string
+	// Empty values mean the secrets are generated
+	rcloneCryptPassword string
+	rcloneCryptSalt     string
+	// Empty value means the existing password is kept
+	kopiaPassword string
 }
 type ToolSettings struct {
 	configPath         string
-	AddRemote          AddRemote
+	backend            Backend
 	Name               string
 	IsEnabled          bool
 	IsPresent          bool
@@ -56,11 +167,16 @@ type ToolSettings struct {
 	NoReplace          bool
 	carefullUpdate     bool
 	singleSection      bool
+	// configPath is a directory holding one file per endpoint
+	configIsDir bool
+	// Checked for IsPresent instead of Name when set, any of them is enough
+	commands []string
+	// Only files are generated, IsPresent is not checked
+	noCommand bool
 }
 
 var RcloneSettings = ToolSettings{
 	configPath:         systemDefaultConfigPaths["rclone"],
-	AddRemote:          addRcloneRemotes,
 	Name:               "rclone",
 	IsEnabled:          true,
 	IsPresent:          false,
@@ -70,7 +186,6 @@ var RcloneSettings = ToolSettings{
 	singleSection:      false}
 var S3cmdSettings = ToolSettings{
 	configPath:         systemDefaultConfigPaths["s3cmd"],
-	AddRemote:          adds3cmdRemote,
 	Name:               "s3cmd",
 	IsEnabled:          true,
 	IsPresent:          false,
@@ -81,7 +196,6 @@ var S3cmdSettings = ToolSettings{
 
 var AwsSettings = ToolSettings{
 	configPath:         systemDefaultConfigPaths["aws"],
-	AddRemote:          addAwsEndPoint,
 	Name:               "aws",
 	IsEnabled:          false,
 	IsPresent:          false,
@@ -90,3 +204,217 @@ var AwsSettings = ToolSettings{
 	carefullUpdate:     true,
 	singleSection:      false,
 }
+
+var ResticSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["restic"],
+	Name:               "restic",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+}
+
+var S5cmdSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["s5cmd"],
+	Name:               "s5cmd",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     true,
+	singleSection:      false,
+}
+
+var McSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["mc"],
+	Name:               "mc",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     true,
+	singleSection:      false,
+}
+
+var S3fsSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["s3fs"],
+	Name:               "s3fs",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+}
+
+var DuckdbSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["duckdb"],
+	Name:               "duckdb",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+}
+
+var GdalSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["gdal"],
+	Name:               "gdal",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     true,
+	singleSection:      false,
+	commands:           []string{"gdalinfo"},
+}
+
+var HadoopSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["hadoop"],
+	Name:               "hadoop",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	commands:           []string{"hadoop"},
+}
+
+var DvcSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["dvc"],
+	Name:               "dvc",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     true,
+	singleSection:      false,
+}
+
+var NextflowSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["nextflow"],
+	Name:               "nextflow",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+}
+
+var SnakemakeSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["snakemake"],
+	Name:               "snakemake",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+}
+
+var FsspecSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["fsspec"],
+	Name:               "fsspec",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	noCommand:          true,
+}
+
+var KopiaSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["kopia"],
+	Name:               "kopia",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     true,
+	singleSection:      false,
+	configIsDir:        true,
+}
+
+var TerraformSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["terraform"],
+	Name:               "terraform",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+	commands:           []string{"tofu", "terraform"},
+}
+
+var KubernetesSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["kubernetes"],
+	Name:               "kubernetes",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+	commands:           []string{"kubectl"},
+}
+
+var ModulefileSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["modulefile"],
+	Name:               "modulefile",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+	noCommand:          true,
+}
+
+var CyberduckSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["cyberduck"],
+	Name:               "cyberduck",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+	commands:           []string{"duck"},
+}
+
+var SystemdSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["systemd"],
+	Name:               "systemd",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+	configIsDir:        true,
+	commands:           []string{"systemctl"},
+}
+
+var SpackSettings = ToolSettings{
+	configPath:         systemDefaultConfigPaths["spack"],
+	Name:               "spack",
+	IsEnabled:          false,
+	IsPresent:          false,
+	ValidationDisabled: false,
+	NoReplace:          true,
+	carefullUpdate:     false,
+	singleSection:      false,
+}
@@ -0,0 +1,417 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Aws shared config and credentials files. Unlike in ini files a key
+// can hold indented nested keys, like s3 in a services section:
+//
+//	[services lumi-465000001]
+//	s3 =
+//	  endpoint_url = https://lumidata.eu
+//
+// Comments, blank lines and the order of sections and keys are kept
+// when the file is written, only changed keys are reformatted.
+
+type AwsConfigFormat struct{}
+
+type AwsConfig struct {
+	// Lines of a file without sections
+	preamble []string
+	sections []*awsConfigSection
+}
+
+type awsConfigSection struct {
+	name string
+	// Comments and blank lines before the header
+	leading []string
+	// Empty for new sections
+	header string
+	keys   []*awsConfigKey
+}
+
+// Comments, blank lines and lines which are not keys have no name
+type awsConfigKey struct {
+	name   string
+	value  string
+	line   string
+	indent int
+	// The line is generated from name and value when set
+	changed bool
+	// Indented lines following the key
+	nested []*awsConfigKey
+}
+
+func (AwsConfigFormat) Name() string {
+	return "aws"
+}
+
+func (AwsConfigFormat) Read(filename string) (ConfigFile, error) {
+	return ReadAwsConfig(filename)
+}
+
+// A missing file gives a config without sections
+func ReadAwsConfig(filename string) (*AwsConfig, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return &AwsConfig{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseAwsConfig(string(data)), nil
+}
+
+// Section name in the aws config file, e.g. profile, sso-session or services
+func AwsSectionName(kind string, name string) string {
+	if kind == "profile" && name == "default" {
+		return name
+	}
+	return fmt.Sprintf("%s %s", kind, name)
+}
+
+// Sections of the credentials file and the default profile have no kind
+func splitAwsSectionName(section string) (string, string) {
+	kind, name, found := strings.Cut(section, " ")
+	if !found {
+		return "profile", section
+	}
+	return kind, name
+}
+
+func normalizeAwsSectionName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func isAwsComment(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";")
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func parseAwsKey(line string) *awsConfigKey {
+	trimmed := strings.TrimSpace(line)
+	i := strings.IndexAny(trimmed, "=:")
+	if i < 0 {
+		return &awsConfigKey{line: line}
+	}
+	return &awsConfigKey{
+		name:   strings.TrimSpace(trimmed[:i]),
+		value:  strings.TrimSpace(trimmed[i+1:]),
+		line:   line,
+		indent: lineIndent(line)}
+}
+
+func commentKeys(lines []string) []*awsConfigKey {
+	var keys []*awsConfigKey
+	for _, line := range lines {
+		keys = append(keys, &awsConfigKey{line: line})
+	}
+	return keys
+}
+
+// Lines indented deeper than the previous key belong to it, as with
+// the python configparser used by the aws cli
+func parseAwsConfig(data string) *AwsConfig {
+	c := &AwsConfig{}
+	var section *awsConfigSection
+	var current *awsConfigKey
+	var pending []string
+	data = strings.TrimSuffix(data, "\n")
+	if data == "" {
+		return c
+	}
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		switch {
+		case trimmed == "" || isAwsComment(trimmed):
+			pending = append(pending, line)
+		case lineIndent(line) == 0 && strings.HasPrefix(trimmed, "[") && strings.LastIndex(trimmed, "]") > 0:
+			name := trimmed[1:strings.LastIndex(trimmed, "]")]
+			section = &awsConfigSection{name: normalizeAwsSectionName(name), leading: pending, header: line}
+			c.sections = append(c.sections, section)
+			current, pending = nil, nil
+		case section == nil:
+			pending = append(pending, line)
+		case current != nil && lineIndent(line) > current.indent:
+			current.nested = append(current.nested, commentKeys(pending)...)
+			current.nested = append(current.nested, parseAwsKey(line))
+			pending = nil
+		default:
+			section.keys = append(section.keys, commentKeys(pending)...)
+			key := parseAwsKey(line)
+			section.keys = append(section.keys, key)
+			current, pending = nil, nil
+			if key.name != "" {
+				current = key
+			}
+		}
+	}
+	if section == nil {
+		c.preamble = pending
+	} else {
+		section.keys = append(section.keys, commentKeys(pending)...)
+	}
+	return c
+}
+
+func (c *AwsConfig) section(name string) *awsConfigSection {
+	name = normalizeAwsSectionName(name)
+	for _, s := range c.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func findAwsKey(keys []*awsConfigKey, name string) *awsConfigKey {
+	for _, k := range keys {
+		if k.name != "" && k.name == name {
+			return k
+		}
+	}
+	return nil
+}
+
+func (c *AwsConfig) SectionNames() []string {
+	var names []string
+	for _, s := range c.sections {
+		if !StringInSlice(s.name, names) {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+func (c *AwsConfig) HasSection(name string) bool {
+	return c.section(name) != nil
+}
+
+// Names of the profiles, with or without the profile prefix
+func (c *AwsConfig) Profiles() []string {
+	var profiles []string
+	for _, section := range c.SectionNames() {
+		kind, name := splitAwsSectionName(section)
+		if kind == "profile" && !StringInSlice(name, profiles) {
+			profiles = append(profiles, name)
+		}
+	}
+	return profiles
+}
+
+func (c *AwsConfig) Get(section string, key string) (string, bool) {
+	s := c.section(section)
+	if s == nil {
+		return "", false
+	}
+	k := findAwsKey(s.keys, key)
+	if k == nil {
+		return "", false
+	}
+	return k.value, true
+}
+
+// Value of a key nested under another key, e.g. endpoint_url under s3
+func (c *AwsConfig) GetNested(section string, key string, nestedKey string) (string, bool) {
+	s := c.section(section)
+	if s == nil {
+		return "", false
+	}
+	k := findAwsKey(s.keys, key)
+	if k == nil {
+		return "", false
+	}
+	n := findAwsKey(k.nested, nestedKey)
+	if n == nil {
+		return "", false
+	}
+	return n.value, true
+}
+
+// Values which are maps are written as nested keys
+func (c *AwsConfig) UpsertSection(name string, values map[string]any, replace bool) error {
+	s := c.section(name)
+	if s == nil {
+		s = &awsConfigSection{name: normalizeAwsSectionName(name)}
+		if content := c.String(); content != "" && !strings.HasSuffix(content, "\n\n") {
+			s.leading = []string{""}
+		}
+		c.sections = append(c.sections, s)
+	}
+	s.keys = setAwsKeys(s.keys, values, replace)
+	return nil
+}
+
+// Keys are changed in place and new keys added at the end,
+// with replace the keys not in values are removed
+func setAwsKeys(keys []*awsConfigKey, values map[string]any, replace bool) []*awsConfigKey {
+	var result []*awsConfigKey
+	done := make(map[string]bool)
+	for _, k := range keys {
+		if k.name == "" {
+			result = append(result, k)
+			continue
+		}
+		value, found := values[k.name]
+		if done[k.name] || (!found && replace) {
+			continue
+		}
+		if found {
+			k.set(value, replace)
+			done[k.name] = true
+		}
+		result = append(result, k)
+	}
+	for _, name := range SortedKeys(values) {
+		if !done[name] {
+			k := &awsConfigKey{name: name, changed: true}
+			k.set(values[name], replace)
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+func (k *awsConfigKey) set(value any, replace bool) {
+	var nested map[string]any
+	switch v := value.(type) {
+	case map[string]any:
+		nested = v
+	case map[string]string:
+		nested = toAnyMap(v)
+	default:
+		text := fmt.Sprint(value)
+		if text != k.value || len(k.nested) > 0 {
+			k.value, k.nested, k.changed = text, nil, true
+		}
+		return
+	}
+	if k.value != "" {
+		k.value, k.nested, k.changed = "", nil, true
+	}
+	k.nested = setAwsKeys(k.nested, nested, replace)
+}
+
+// Comments before the header of a removed section are kept
+func (c *AwsConfig) DeleteSection(name string) bool {
+	name = normalizeAwsSectionName(name)
+	found := false
+	var sections []*awsConfigSection
+	var orphaned []string
+	for _, s := range c.sections {
+		if s.name != name {
+			s.leading = append(orphaned, s.leading...)
+			orphaned = nil
+			sections = append(sections, s)
+			continue
+		}
+		found = true
+		for _, line := range s.leading {
+			if isAwsComment(strings.TrimSpace(line)) {
+				orphaned = append(orphaned, s.leading...)
+				break
+			}
+		}
+	}
+	if len(sections) > 0 {
+		last := sections[len(sections)-1]
+		last.keys = append(last.keys, commentKeys(orphaned)...)
+	} else {
+		c.preamble = append(c.preamble, orphaned...)
+	}
+	// No blank lines at the start of the file when the first section was removed
+	if len(c.preamble) == 0 && len(sections) > 0 {
+		for len(sections[0].leading) > 0 && strings.TrimSpace(sections[0].leading[0]) == "" {
+			sections[0].leading = sections[0].leading[1:]
+		}
+	}
+	c.sections = sections
+	return found
+}
+
+func (k *awsConfigKey) lines(indent string) []string {
+	line := k.line
+	if k.changed && k.value == "" && len(k.nested) > 0 {
+		line = fmt.Sprintf("%s%s =", indent, k.name)
+	} else if k.changed {
+		line = fmt.Sprintf("%s%s = %s", indent, k.name, k.value)
+	}
+	lines := []string{line}
+	nestedIndent := indent + "  "
+	if !k.changed {
+		nestedIndent = k.line[:k.indent] + "  "
+	}
+	// Keys read from the file keep their indent when changed
+	for _, n := range k.nested {
+		if n.name != "" && n.line != "" {
+			nestedIndent = n.line[:n.indent]
+			break
+		}
+	}
+	for _, n := range k.nested {
+		lines = append(lines, n.lines(nestedIndent)...)
+	}
+	return lines
+}
+
+func (c *AwsConfig) String() string {
+	lines := append([]string{}, c.preamble...)
+	for _, s := range c.sections {
+		lines = append(lines, s.leading...)
+		if s.header == "" {
+			lines = append(lines, fmt.Sprintf("[%s]", s.name))
+		} else {
+			lines = append(lines, s.header)
+		}
+		for _, k := range s.keys {
+			lines = append(lines, k.lines("")...)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (c *AwsConfig) Write(filename string) error {
+	return os.WriteFile(filename, []byte(c.String()), 0600)
+}
+
+// The default profile is removed with the profile it is a copy of
+func DeleteAwsSectionsFromFile(filename string, sectionNames []string) error {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	cfg, err := ReadAwsConfig(filename)
+	if err != nil {
+		return err
+	}
+	originalName, hasOriginal := cfg.Get("default", "original_name")
+	if cfg.HasSection("default") && !hasOriginal {
+		fmt.Print("WARNING: Found default section but could not guess the related section\n")
+	}
+	for _, name := range sectionNames {
+		deleteSectionVerbose(cfg, name, filename)
+		if hasOriginal && originalName == name {
+			if cfg.DeleteSection("default") {
+				fmt.Print("WARNING: Also deleted default section\n")
+			}
+		}
+	}
+	return cfg.Write(filename)
+}
+
+// Profiles in filename except default, none if the file does not exist
+func ListAwsProfiles(filename string) ([]string, error) {
+	cfg, err := ReadAwsConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	return RemoveStringFromSlice(cfg.Profiles(), "default"), nil
+}
@@ -0,0 +1,293 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAwsConfigRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"comments only", "# comment\n; other comment\n"},
+		{"credentials", "[lumi-465000001]\naws_access_key_id = AK\naws_secret_access_key=SK\n"},
+		{"profile prefix", "[default]\nregion = lumi\n\n[profile lumi-465000001]\noutput = json\n"},
+		{"tabs and comments", "# leading\n[profile a]\n\tregion\t=\tlumi\n; between\n#another\n\n[profile b]\nregion: lumi\n"},
+		{"nested", "[services lumi-465000001]\ns3 =\n  endpoint_url = https://lumidata.eu\n  # nested comment\n  addressing_style = path\n"},
+		{"sso session", "[profile sso]\nsso_session = my-sso\n\n[sso-session my-sso]\nsso_region = us-east-1\nsso_registration_scopes = sso:account:access\n"},
+		{"extra spaces in header", "[profile   spaced]\nregion = lumi\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAwsConfig(tt.data).String(); got != tt.data {
+				t.Errorf("round trip changed the config\ngot:\n%q\nwant:\n%q", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestAwsConfigGet(t *testing.T) {
+	data := "[profile a]\nregion = lumi\ns3 =\n\tendpoint_url = https://lumidata.eu\n\n[b]\nregion: other\n\n[profile   c]\nregion = spaced\n"
+	tests := []struct {
+		name      string
+		section   string
+		key       string
+		nestedKey string
+		want      string
+		found     bool
+	}{
+		{"key", "profile a", "region", "", "lumi", true},
+		{"colon separator", "b", "region", "", "other", true},
+		{"normalized section name", "profile c", "region", "", "spaced", true},
+		{"missing section", "profile b", "region", "", "", false},
+		{"missing key", "profile a", "output", "", "", false},
+		{"nested key", "profile a", "s3", "endpoint_url", "https://lumidata.eu", true},
+		{"missing nested key", "profile a", "s3", "addressing_style", "", false},
+	}
+	cfg := parseAwsConfig(data)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var found bool
+			if tt.nestedKey == "" {
+				got, found = cfg.Get(tt.section, tt.key)
+			} else {
+				got, found = cfg.GetNested(tt.section, tt.key, tt.nestedKey)
+			}
+			if got != tt.want || found != tt.found {
+				t.Errorf("got (%q, %v), want (%q, %v)", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestAwsConfigProfiles(t *testing.T) {
+	data := "[default]\n[profile a]\n[b]\n[sso-session s]\n[services lumi]\n[profile b]\n"
+	want := []string{"default", "a", "b"}
+	if got := parseAwsConfig(data).Profiles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAwsConfigUpsertSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		section string
+		values  map[string]any
+		replace bool
+		want    string
+	}{
+		{
+			name:    "new section in empty file",
+			data:    "",
+			section: "lumi-465000001",
+			values:  map[string]any{"aws_secret_access_key": "SK", "aws_access_key_id": "AK"},
+			want:    "[lumi-465000001]\naws_access_key_id = AK\naws_secret_access_key = SK\n",
+		},
+		{
+			name:    "new section after existing",
+			data:    "[other]\nregion = lumi\n",
+			section: "profile new",
+			values:  map[string]any{"region": "lumi"},
+			want:    "[other]\nregion = lumi\n\n[profile new]\nregion = lumi\n",
+		},
+		{
+			name:    "careful keeps other keys and formatting",
+			data:    "[a]\n# keep me\nregion\t=\tlumi\noutput = json\n",
+			section: "a",
+			values:  map[string]any{"output": "text", "new": "value"},
+			want:    "[a]\n# keep me\nregion\t=\tlumi\noutput = text\nnew = value\n",
+		},
+		{
+			name:    "unchanged keys are not reformatted",
+			data:    "[a]\nregion:lumi\n",
+			section: "a",
+			values:  map[string]any{"region": "lumi"},
+			want:    "[a]\nregion:lumi\n",
+		},
+		{
+			name:    "replace removes other keys",
+			data:    "[a]\n# keep me\nregion = lumi\noutput = json\n",
+			section: "a",
+			values:  map[string]any{"output": "text"},
+			replace: true,
+			want:    "[a]\n# keep me\noutput = text\n",
+		},
+		{
+			name:    "new nested map",
+			data:    "[services lumi]\n",
+			section: "services lumi",
+			values:  map[string]any{"s3": map[string]any{"endpoint_url": "https://lumidata.eu"}},
+			want:    "[services lumi]\ns3 =\n  endpoint_url = https://lumidata.eu\n",
+		},
+		{
+			name:    "careful nested map keeps other nested keys and indent",
+			data:    "[services lumi]\ns3 =\n    endpoint_url = https://old\n    addressing_style = path\n",
+			section: "services lumi",
+			values:  map[string]any{"s3": map[string]string{"endpoint_url": "https://lumidata.eu"}},
+			want:    "[services lumi]\ns3 =\n    endpoint_url = https://lumidata.eu\n    addressing_style = path\n",
+		},
+		{
+			name:    "replace nested map",
+			data:    "[services lumi]\ns3 =\n    endpoint_url = https://old\n    addressing_style = path\n",
+			section: "services lumi",
+			values:  map[string]any{"s3": map[string]any{"endpoint_url": "https://lumidata.eu"}},
+			replace: true,
+			want:    "[services lumi]\ns3 =\n    endpoint_url = https://lumidata.eu\n",
+		},
+		{
+			name:    "value replaced by nested map",
+			data:    "[profile a]\ns3 = old\n",
+			section: "profile a",
+			values:  map[string]any{"s3": map[string]any{"multipart_chunksize": 15}},
+			want:    "[profile a]\ns3 =\n  multipart_chunksize = 15\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseAwsConfig(tt.data)
+			err := cfg.UpsertSection(tt.section, tt.values, tt.replace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.String(); got != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+			// The written file parses back to the same content
+			if got := parseAwsConfig(cfg.String()).String(); got != tt.want {
+				t.Errorf("reparsed:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAwsConfigDeleteSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		section string
+		found   bool
+		want    string
+	}{
+		{
+			name:    "missing section",
+			data:    "[a]\nregion = lumi\n",
+			section: "b",
+			found:   false,
+			want:    "[a]\nregion = lumi\n",
+		},
+		{
+			name:    "middle section",
+			data:    "[a]\nregion = lumi\n\n[b]\nregion = lumi\n\n[c]\nregion = lumi\n",
+			section: "b",
+			found:   true,
+			want:    "[a]\nregion = lumi\n\n[c]\nregion = lumi\n",
+		},
+		{
+			name:    "first section",
+			data:    "[a]\nregion = lumi\n\n[b]\nregion = lumi\n",
+			section: "a",
+			found:   true,
+			want:    "[b]\nregion = lumi\n",
+		},
+		{
+			name:    "comment before header is kept",
+			data:    "[a]\nregion = lumi\n# about b\n[b]\nregion = lumi\n[c]\nregion = lumi\n",
+			section: "b",
+			found:   true,
+			want:    "[a]\nregion = lumi\n# about b\n[c]\nregion = lumi\n",
+		},
+		{
+			name:    "comment before the last section moves to the previous one",
+			data:    "[a]\nregion = lumi\n# about b\n[b]\nregion = lumi\n",
+			section: "b",
+			found:   true,
+			want:    "[a]\nregion = lumi\n# about b\n",
+		},
+		{
+			name:    "comment before the only section moves to the preamble",
+			data:    "# about a\n[a]\nregion = lumi\n",
+			section: "a",
+			found:   true,
+			want:    "# about a\n",
+		},
+		{
+			name:    "normalized section name",
+			data:    "[profile   a]\nregion = lumi\n",
+			section: "profile a",
+			found:   true,
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseAwsConfig(tt.data)
+			if found := cfg.DeleteSection(tt.section); found != tt.found {
+				t.Errorf("found %v, want %v", found, tt.found)
+			}
+			if got := cfg.String(); got != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAwsSectionsFromFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		sections []string
+		want     string
+	}{
+		{
+			name:     "default copy is removed with its profile",
+			data:     "[default]\noriginal_name = lumi-1\nregion = lumi\n\n[lumi-1]\nregion = lumi\n\n[lumi-2]\nregion = lumi\n",
+			sections: []string{"lumi-1"},
+			want:     "[lumi-2]\nregion = lumi\n",
+		},
+		{
+			name:     "default of another profile is kept",
+			data:     "[default]\noriginal_name = lumi-2\n\n[lumi-1]\nregion = lumi\n\n[lumi-2]\nregion = lumi\n",
+			sections: []string{"lumi-1"},
+			want:     "[default]\noriginal_name = lumi-2\n\n[lumi-2]\nregion = lumi\n",
+		},
+		{
+			name:     "default without original name is kept",
+			data:     "[default]\nregion = lumi\n\n[lumi-1]\nregion = lumi\n",
+			sections: []string{"lumi-1"},
+			want:     "[default]\nregion = lumi\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "credentials")
+			err := os.WriteFile(filename, []byte(tt.data), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = DeleteAwsSectionsFromFile(filename, tt.sections)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", string(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAwsSectionsFromMissingFile(t *testing.T) {
+	err := DeleteAwsSectionsFromFile(filepath.Join(t.TempDir(), "missing"), []string{"lumi-1"})
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v, want a not exist error", err)
+	}
+}
@@ -0,0 +1,188 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Text based configs, where the file can not be parsed by us, are updated by
+// keeping each endpoint in a block between two marker comments
+// e.g
+//
+//	# BEGIN lumio-conf lumi-462000001
+//	...
+//	# END lumio-conf lumi-462000001
+
+func blockStart(commentPrefix string, name string) string {
+	return fmt.Sprintf("%s BEGIN lumio-conf %s", commentPrefix, name)
+}
+
+func blockEnd(commentPrefix string, name string) string {
+	return fmt.Sprintf("%s END lumio-conf %s", commentPrefix, name)
+}
+
+func parseBlocks(content string, commentPrefix string) (map[string]string, error) {
+	blocks := make(map[string]string)
+	startPrefix := blockStart(commentPrefix, "")
+	current := ""
+	var blockLines []string
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		if !inBlock && strings.HasPrefix(line, startPrefix) {
+			current = strings.TrimSpace(strings.TrimPrefix(line, startPrefix))
+			inBlock = true
+			blockLines = nil
+		} else if inBlock && strings.TrimSpace(line) == blockEnd(commentPrefix, current) {
+			blocks[current] = strings.Join(blockLines, "\n")
+			inBlock = false
+		} else if inBlock {
+			blockLines = append(blockLines, line)
+		}
+	}
+	if inBlock {
+		return nil, fmt.Errorf("no end marker for block %s", current)
+	}
+	return blocks, nil
+}
+
+// Lines of filename which are not inside a block, e.g. written by hand
+func ReadLinesOutsideBlocks(filename string, commentPrefix string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	_, err = parseBlocks(string(data), commentPrefix)
+	if err != nil {
+		return nil, err
+	}
+	startPrefix := blockStart(commentPrefix, "")
+	var lines []string
+	blockEndLine := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if blockEndLine != "" {
+			if strings.TrimSpace(line) == blockEndLine {
+				blockEndLine = ""
+			}
+		} else if strings.HasPrefix(line, startPrefix) {
+			blockEndLine = blockEnd(commentPrefix, strings.TrimSpace(strings.TrimPrefix(line, startPrefix)))
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func ReadConfigBlocks(filename string, commentPrefix string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlocks(string(data), commentPrefix)
+}
+
+func ListConfigBlocks(filename string, commentPrefix string) ([]string, error) {
+	if !CheckFileExists(filename) {
+		return nil, nil
+	}
+	blocks, err := ReadConfigBlocks(filename, commentPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return SortedKeys(blocks), nil
+}
+
+// Rewrite filename, replacing blocks already in the file and appending new ones.
+// Blocks with an empty content are removed.
+func modifyConfigBlocks(filename string, commentPrefix string, data map[string]string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	written := make(map[string]bool)
+	var result []string
+	startPrefix := blockStart(commentPrefix, "")
+	skipUntil := ""
+	removedBlock := false
+	for _, line := range lines {
+		if skipUntil != "" {
+			if strings.TrimSpace(line) == skipUntil {
+				skipUntil = ""
+			}
+			continue
+		}
+		// Do not leave the separating empty line of a removed block behind
+		if removedBlock && line == "" {
+			removedBlock = false
+			continue
+		}
+		removedBlock = false
+		if strings.HasPrefix(line, startPrefix) {
+			name := strings.TrimSpace(strings.TrimPrefix(line, startPrefix))
+			if newContent, found := data[name]; found {
+				skipUntil = blockEnd(commentPrefix, name)
+				if newContent != "" {
+					result = append(result, line, newContent, skipUntil)
+				} else {
+					removedBlock = true
+				}
+				written[name] = true
+				continue
+			}
+		}
+		result = append(result, line)
+	}
+	if skipUntil != "" {
+		return fmt.Errorf("no end marker %s in %s", skipUntil, filename)
+	}
+	output := strings.TrimRight(strings.Join(result, "\n"), "\n")
+	for _, name := range SortedKeys(data) {
+		newContent := data[name]
+		if written[name] || newContent == "" {
+			continue
+		}
+		if output != "" {
+			output += "\n\n"
+		}
+		output += strings.Join([]string{blockStart(commentPrefix, name), newContent, blockEnd(commentPrefix, name)}, "\n")
+	}
+	if output != "" {
+		output += "\n"
+	}
+	return os.WriteFile(filename, []byte(output), 0600)
+}
+
+// Block counterpart of UpdateConfig, each block is replaced as a whole
+func UpdateBlockConfig(blocks map[string]string, commentPrefix string, oldConfigFilePath string, newConfigFilePath string) (string, error) {
+	info, err := prepareTempConfig(oldConfigFilePath, newConfigFilePath)
+	if err != nil {
+		return info, err
+	}
+	err = modifyConfigBlocks(newConfigFilePath, commentPrefix, blocks)
+	if err != nil {
+		return "Failed while editing config blocks", err
+	}
+	return "", nil
+}
+
+func DeleteConfigBlocksFromFile(filename string, commentPrefix string, names []string) error {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	existing, err := ReadConfigBlocks(filename, commentPrefix)
+	if err != nil {
+		return err
+	}
+	toDelete := make(map[string]string)
+	for _, name := range names {
+		if _, found := existing[name]; found {
+			toDelete[name] = ""
+			fmt.Printf("Deleted section %s in file %s\n", name, filename)
+		} else {
+			fmt.Printf("WARNING: While deleting section %s in file %s, no such section\n", name, filename)
+		}
+	}
+	return modifyConfigBlocks(filename, commentPrefix, toDelete)
+}
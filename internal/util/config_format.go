@@ -0,0 +1,148 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Config files are edited as named sections holding keys and values,
+// the format decides how sections are stored in the file.
+
+type ConfigFormat interface {
+	Name() string
+	// An empty file gives a config without sections
+	Read(filename string) (ConfigFile, error)
+}
+
+type ConfigFile interface {
+	SectionNames() []string
+	// Existing keys of the section are kept unless replace is set
+	UpsertSection(name string, values map[string]any, replace bool) error
+	// Returns false if there was no such section
+	DeleteSection(name string) bool
+	Write(filename string) error
+}
+
+// Format by name, parentKey is the key holding the sections for the
+// formats which support it, empty means the top level
+func GetConfigFormat(name string, parentKey string) (ConfigFormat, error) {
+	switch name {
+	case "ini":
+		if parentKey != "" {
+			return nil, errors.New("ini configs have no parent key")
+		}
+		return IniFormat{}, nil
+	case "json":
+		return JsonFormat{ParentKey: parentKey}, nil
+	case "yaml":
+		return YamlFormat{ParentKey: parentKey}, nil
+	case "toml":
+		return TomlFormat{ParentKey: parentKey}, nil
+	case "xml":
+		if parentKey != "" {
+			return nil, errors.New("xml configs have no parent key")
+		}
+		return XmlFormat{}, nil
+	case "aws":
+		if parentKey != "" {
+			return nil, errors.New("aws configs have no parent key")
+		}
+		return AwsConfigFormat{}, nil
+	}
+	return nil, fmt.Errorf("unknown config format %s, valid options are: ini json yaml toml xml aws", name)
+}
+
+// Copy oldConfigFilePath to newConfigFilePath and add the sections in config to it.
+// Existing sections are replaced unless carefull is set, in which case only the given
+// keys are changed. With singleSectionOnly all other sections are removed
+func UpdateConfigFile(format ConfigFormat, config map[string]map[string]any, oldConfigFilePath string, newConfigFilePath string, carefull bool, singleSectionOnly bool) (string, error) {
+	info, err := prepareTempConfig(oldConfigFilePath, newConfigFilePath)
+	if err != nil {
+		return info, err
+	}
+	err = modifyConfigSections(format, newConfigFilePath, config, !carefull, singleSectionOnly)
+	if err != nil {
+		return fmt.Sprintf("Failed while editing %s sections", format.Name()), err
+	}
+	return "", nil
+}
+
+func modifyConfigSections(format ConfigFormat, filename string, data map[string]map[string]any, setSection bool, oneSectionOnly bool) error {
+	cfg, err := format.Read(filename)
+	if err != nil {
+		return err
+	}
+	if oneSectionOnly {
+		for _, sectionName := range cfg.SectionNames() {
+			if _, found := data[sectionName]; !found {
+				cfg.DeleteSection(sectionName)
+			}
+		}
+	}
+	for _, sectionName := range SortedKeys(data) {
+		err = cfg.UpsertSection(sectionName, data[sectionName], setSection)
+		if err != nil {
+			return err
+		}
+	}
+	return cfg.Write(filename)
+}
+
+func deleteSectionVerbose(cfg ConfigFile, name string, filename string) {
+	if cfg.DeleteSection(name) {
+		fmt.Printf("Deleted section %s in file %s\n", name, filename)
+	} else {
+		fmt.Printf("WARNING: While deleting section %s in file %s, no such section\n", name, filename)
+	}
+}
+
+func DeleteSectionsFromFile(format ConfigFormat, filename string, sectionNames []string) error {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	cfg, err := format.Read(filename)
+	if err != nil {
+		return err
+	}
+	for _, name := range sectionNames {
+		deleteSectionVerbose(cfg, name, filename)
+	}
+	return cfg.Write(filename)
+}
+
+// Section names in filename, no sections if the file does not exist
+func ListSections(format ConfigFormat, filename string) ([]string, error) {
+	if !CheckFileExists(filename) {
+		return nil, nil
+	}
+	cfg, err := format.Read(filename)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.SectionNames(), nil
+}
+
+func toStringMap(values map[string]any) map[string]string {
+	result := make(map[string]string)
+	for k, v := range values {
+		result[k] = fmt.Sprint(v)
+	}
+	return result
+}
+
+func toAnyMap(values map[string]string) map[string]any {
+	result := make(map[string]any)
+	for k, v := range values {
+		result[k] = v
+	}
+	return result
+}
+
+func toAnySections(config map[string]map[string]string) map[string]map[string]any {
+	result := make(map[string]map[string]any)
+	for name, values := range config {
+		result[name] = toAnyMap(values)
+	}
+	return result
+}
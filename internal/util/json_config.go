@@ -0,0 +1,128 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Configs in json format keep their sections as objects, either at
+// the top level or under a single key (parentKey), e.g "aliases" for mc.
+// Other content in the file is left as is.
+
+func readJsonFile(filename string) (map[string]any, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	content := make(map[string]any)
+	if len(data) == 0 {
+		return content, nil
+	}
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %s as json, error is: %s", filename, err.Error())
+	}
+	return content, nil
+}
+
+func writeJsonFile(filename string, content map[string]any) error {
+	data, err := json.MarshalIndent(content, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0600)
+}
+
+func getJsonSections(content map[string]any, parentKey string) (map[string]any, error) {
+	if parentKey == "" {
+		return content, nil
+	}
+	if _, found := content[parentKey]; !found {
+		content[parentKey] = make(map[string]any)
+	}
+	sections, ok := content[parentKey].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("value for %s is not a json object", parentKey)
+	}
+	return sections, nil
+}
+
+type JsonFormat struct {
+	ParentKey string
+}
+
+type jsonConfigFile struct {
+	content  map[string]any
+	sections map[string]any
+}
+
+func (JsonFormat) Name() string {
+	return "json"
+}
+
+func (f JsonFormat) Read(filename string) (ConfigFile, error) {
+	content, err := readJsonFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := getJsonSections(content, f.ParentKey)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonConfigFile{content: content, sections: sections}, nil
+}
+
+func (c *jsonConfigFile) SectionNames() []string {
+	return SortedKeys(c.sections)
+}
+
+func (c *jsonConfigFile) UpsertSection(name string, values map[string]any, replace bool) error {
+	section, isObject := c.sections[name].(map[string]any)
+	if !isObject || replace {
+		section = make(map[string]any)
+	}
+	for k, v := range values {
+		section[k] = v
+	}
+	c.sections[name] = section
+	return nil
+}
+
+func (c *jsonConfigFile) DeleteSection(name string) bool {
+	if _, found := c.sections[name]; !found {
+		return false
+	}
+	delete(c.sections, name)
+	return true
+}
+
+func (c *jsonConfigFile) Write(filename string) error {
+	return writeJsonFile(filename, c.content)
+}
+
+// Json counterpart of UpdateConfig
+func UpdateJsonConfig(config map[string]map[string]any, parentKey string, oldConfigFilePath string, newConfigFilePath string, carefull bool, singleSectionOnly bool) (string, error) {
+	return UpdateConfigFile(JsonFormat{ParentKey: parentKey}, config, oldConfigFilePath, newConfigFilePath, carefull, singleSectionOnly)
+}
+
+// Set a top level value unless the file already has one
+func SetJsonDefault(filename string, key string, value any) error {
+	content, err := readJsonFile(filename)
+	if err != nil {
+		return err
+	}
+	if _, found := content[key]; found {
+		return nil
+	}
+	content[key] = value
+	return writeJsonFile(filename, content)
+}
+
+func ListJsonSections(filename string, parentKey string) ([]string, error) {
+	return ListSections(JsonFormat{ParentKey: parentKey}, filename)
+}
+
+func DeleteJsonSectionsFromFile(filename string, parentKey string, sectionNames []string) error {
+	return DeleteSectionsFromFile(JsonFormat{ParentKey: parentKey}, filename, sectionNames)
+}
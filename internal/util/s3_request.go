@@ -0,0 +1,48 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Minimal S3 request for tools which can not check the credentials without
+// side effects (e.g mounting). Lists the buckets using signature v2 in the same
+// way as curl_uppload.sh
+func CheckS3Credentials(url string, accessKey string, secretKey string) error {
+	date := time.Now().UTC().Format(http.TimeFormat)
+	stringToSign := fmt.Sprintf("GET\n\n\n%s\n/", date)
+	mac := hmac.New(sha1.New, []byte(secretKey))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(url, "/")+"/", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Date", date)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS %s:%s", accessKey, signature))
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var s3Err struct {
+		Code string `xml:"Code"`
+	}
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("listing buckets at %s failed with %s (%s)", url, s3Err.Code, resp.Status)
+	}
+	return fmt.Errorf("listing buckets at %s failed with %s", url, resp.Status)
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Toml configs keep their sections as tables, either at the top level
+// or under parentKey. Comments are not kept when the file is written.
+
+type TomlFormat struct {
+	ParentKey string
+}
+
+type tomlConfigFile struct {
+	content  map[string]any
+	sections map[string]any
+}
+
+func (TomlFormat) Name() string {
+	return "toml"
+}
+
+func (f TomlFormat) Read(filename string) (ConfigFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	content := make(map[string]any)
+	_, err = toml.Decode(string(data), &content)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %s as toml, error is: %s", filename, err.Error())
+	}
+	sections := content
+	if f.ParentKey != "" {
+		if _, found := content[f.ParentKey]; !found {
+			content[f.ParentKey] = make(map[string]any)
+		}
+		var isTable bool
+		sections, isTable = content[f.ParentKey].(map[string]any)
+		if !isTable {
+			return nil, fmt.Errorf("value for %s is not a toml table", f.ParentKey)
+		}
+	}
+	return &tomlConfigFile{content: content, sections: sections}, nil
+}
+
+// Only tables are sections, top level keys are left as is
+func (c *tomlConfigFile) SectionNames() []string {
+	var names []string
+	for _, name := range SortedKeys(c.sections) {
+		if _, isTable := c.sections[name].(map[string]any); isTable {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (c *tomlConfigFile) UpsertSection(name string, values map[string]any, replace bool) error {
+	section, isTable := c.sections[name].(map[string]any)
+	if !isTable || replace {
+		section = make(map[string]any)
+	}
+	for k, v := range values {
+		section[k] = v
+	}
+	c.sections[name] = section
+	return nil
+}
+
+func (c *tomlConfigFile) DeleteSection(name string) bool {
+	if _, isTable := c.sections[name].(map[string]any); !isTable {
+		return false
+	}
+	delete(c.sections, name)
+	return true
+}
+
+func (c *tomlConfigFile) Write(filename string) error {
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	err := encoder.Encode(c.content)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0600)
+}
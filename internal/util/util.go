@@ -2,15 +2,18 @@
 package util
 
 import (
+	crand "crypto/rand"
 	"errors"
 	"flag"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"gopkg.in/ini.v1"
@@ -105,6 +108,11 @@ options:
 }
 
 func UpdateConfig(config map[string]map[string]string, oldConfigFilePath string, newConfigFilePath string, carefull bool, singleSectionOnly bool) (string, error) {
+	return UpdateConfigFile(IniFormat{}, toAnySections(config), oldConfigFilePath, newConfigFilePath, carefull, singleSectionOnly)
+}
+
+// Start the temporary config from a copy of the current one
+func prepareTempConfig(oldConfigFilePath string, newConfigFilePath string) (string, error) {
 	_, err := os.Create(newConfigFilePath)
 	if err != nil {
 		return "Failed file creation", err
@@ -113,32 +121,9 @@ func UpdateConfig(config map[string]map[string]string, oldConfigFilePath string,
 	if err != nil {
 		return "Failed chmod", err
 	}
-	info, err := CommitTempConfigFile(oldConfigFilePath, newConfigFilePath)
-	if err != nil {
-		return info, err
-	}
-	remoteConfig := ini.Empty()
-
-	for sectionName, m := range config {
-		for k, v := range m {
-			_, err = remoteConfig.Section(sectionName).NewKey(k, v)
-			if err != nil {
-				return "Failed adding ini section", err
-			}
-		}
-	}
-
-	// Do not delete remote config before setting new values.
-	if carefull {
-		err = updateIniSections(newConfigFilePath, remoteConfig, singleSectionOnly)
-	} else {
-		err = setIniSections(newConfigFilePath, remoteConfig, singleSectionOnly)
-	}
-	if err != nil {
-		return "Failed while editing ini sections", err
-	}
-	return "", nil
+	return CommitTempConfigFile(oldConfigFilePath, newConfigFilePath)
 }
+
 func RemoveWhiteSpaceAndSplit(a string) []string {
 	reg, _ := regexp.Compile(`\s+`)
 	return strings.Split(reg.ReplaceAllString(a, ""), ",")
@@ -148,14 +133,13 @@ func DeleteIniSectionsFromFile(filename string, sectionNames []string) error {
 	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
 		return err
 	}
-	cfg := ini.Empty()
-	err := cfg.Append(filename)
+	cfg, err := readIniConfigFile(filename)
 	if err != nil {
 		return err
 	}
 	var original_value = ""
-	if cfg.HasSection("default") {
-		df, _ := cfg.GetSection("default")
+	if cfg.file.HasSection("default") {
+		df, _ := cfg.file.GetSection("default")
 		if df.HasKey("original_name") {
 			original_value = df.Key("original_name").String()
 		} else {
@@ -163,23 +147,22 @@ func DeleteIniSectionsFromFile(filename string, sectionNames []string) error {
 		}
 	}
 	for _, name := range sectionNames {
-		if cfg.HasSection(name) {
-			cfg.DeleteSection(name)
-			fmt.Printf("Deleted section %s in file %s\n", name, filename)
-		} else {
-			fmt.Printf("WARNING: While deleting section %s in file %s, no such section\n", name, filename)
-		}
+		deleteSectionVerbose(cfg, name, filename)
 		if original_value == name {
-			if cfg.HasSection("default") {
-				cfg.DeleteSection("default")
+			if cfg.DeleteSection("default") {
 				fmt.Print("WARNING: Also deleted default section\n")
 			}
 		}
 
 	}
-	err = cfg.SaveTo(filename)
-	return err
+	return cfg.Write(filename)
+
+}
 
+// The default section of s3cmd is a copy of another section
+func ListIniSections(filename string) ([]string, error) {
+	names, err := ListSections(IniFormat{}, filename)
+	return RemoveStringFromSlice(names, "default"), err
 }
 
 func IsDirectory(path string) bool {
@@ -192,46 +175,61 @@ func IsDirectory(path string) bool {
 	return fileInfo.IsDir()
 }
 
-func updateIniSections(filename string, data *ini.File, singleSection bool) error {
-	return modifySections(filename, data, false, singleSection)
+type IniFormat struct{}
+
+type iniConfigFile struct {
+	file *ini.File
+}
+
+func (IniFormat) Name() string {
+	return "ini"
 }
-func modifySections(filename string, data *ini.File, setSection bool, oneSectionOnly bool) error {
+
+func (IniFormat) Read(filename string) (ConfigFile, error) {
+	return readIniConfigFile(filename)
+}
+
+func readIniConfigFile(filename string) (*iniConfigFile, error) {
 	cfg := ini.Empty()
 	err := cfg.Append(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if oneSectionOnly {
-		for _, sectionName := range cfg.SectionStrings() {
-			if !StringInSlice(sectionName, data.SectionStrings()) {
-				cfg.DeleteSection(sectionName)
-			}
-		}
-	}
-	for _, sectionName := range data.SectionStrings() {
+	return &iniConfigFile{file: cfg}, nil
+}
 
-		if cfg.HasSection(sectionName) && setSection {
-			cfg.DeleteSection(sectionName)
-		}
-		_, err := cfg.NewSection(sectionName)
+func (c *iniConfigFile) SectionNames() []string {
+	return RemoveStringFromSlice(c.file.SectionStrings(), ini.DefaultSection)
+}
+
+func (c *iniConfigFile) UpsertSection(name string, values map[string]any, replace bool) error {
+	if c.file.HasSection(name) && replace {
+		c.file.DeleteSection(name)
+	}
+	_, err := c.file.NewSection(name)
+	if err != nil {
+		return err
+	}
+	section, _ := c.file.GetSection(name)
+	for _, key := range SortedKeys(values) {
+		_, err = section.NewKey(key, fmt.Sprint(values[key]))
 		if err != nil {
 			return err
 		}
-		section, _ := cfg.GetSection(sectionName)
-		section2, _ := data.GetSection(sectionName)
-		for _, key := range section2.KeyStrings() {
-			_, err = section.NewKey(key, section2.Key(key).String())
-			if err != nil {
-				return err
-			}
-		}
 	}
-	err = cfg.SaveTo(filename)
-	return err
+	return nil
+}
+
+func (c *iniConfigFile) DeleteSection(name string) bool {
+	if !c.file.HasSection(name) {
+		return false
+	}
+	c.file.DeleteSection(name)
+	return true
 }
 
-func setIniSections(filename string, data *ini.File, singleSection bool) error {
-	return modifySections(filename, data, true, singleSection)
+func (c *iniConfigFile) Write(filename string) error {
+	return c.file.SaveTo(filename)
 }
 
 func PrintErr(err error, info string) {
@@ -246,6 +244,7 @@ func PrintErr(err error, info string) {
 }
 
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+var secretRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
 func randStringRunes(n int) string {
 	b := make([]rune, n)
@@ -255,6 +254,20 @@ func randStringRunes(n int) string {
 	return string(b)
 }
 
+// Random alphanumeric string for generated passwords
+func GenerateSecret(n int) (string, error) {
+	b := make([]rune, n)
+	max := big.NewInt(int64(len(secretRunes)))
+	for i := range b {
+		r, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = secretRunes[r.Int64()]
+	}
+	return string(b), nil
+}
+
 func CreateTmpDir(path string) (string, error) {
 	usern, _ := user.Current()
 
@@ -293,18 +306,41 @@ func CheckCommand(command string, args ...string) error {
 
 }
 
-func ReplaceInFile(path string, pattern *regexp.Regexp, replacement string) {
-	read, err := os.ReadFile(path)
+// Like CheckCommand but with env added to the environment of the command only
+func CheckCommandEnv(env []string, command string, args ...string) error {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	ret, err := cmd.CombinedOutput()
 	if err != nil {
-		panic(err)
+		if len(ret) == 0 {
+			return err
+		}
+		return errors.New(string(ret))
 	}
-	newContents := pattern.ReplaceAllString(string(read), replacement)
+	return nil
+}
 
-	err = os.WriteFile(path, []byte(newContents), 0)
+// Like CheckCommand but returns stdout on success
+func CommandOutput(command string, args ...string) (string, error) {
+	return CommandOutputEnv(nil, command, args...)
+}
+
+// Like CommandOutput with the variables in env added to the environment of the command
+func CommandOutputEnv(env []string, command string, args ...string) (string, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	ret, err := cmd.Output()
 	if err != nil {
-		panic(err)
+		if stderr.Len() == 0 {
+			return "", err
+		}
+		return "", errors.New(stderr.String())
 	}
+	return string(ret), nil
 }
+
 func CheckFileExists(filePath string) bool {
 	_, error := os.Stat(filePath)
 	//return !os.IsNotExist(err)
@@ -361,3 +397,12 @@ func MergeMaps[K comparable, V any](m1, m2 map[K]V) map[K]V {
 
 	return merged
 }
+
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,229 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Hadoop style xml configuration, a flat list of properties.
+// Properties belonging to the same section are marked using the description.
+
+type xmlProperty struct {
+	XMLName     xml.Name `xml:"property"`
+	Name        string   `xml:"name"`
+	Value       string   `xml:"value"`
+	Description string   `xml:"description,omitempty"`
+	Final       string   `xml:"final,omitempty"`
+}
+
+// A child of the configuration element. Everything but the properties,
+// like comments and xi:include elements, is written back as read
+type xmlNode struct {
+	// Empty for new properties
+	raw      string
+	property *xmlProperty
+}
+
+type xmlConfiguration struct {
+	// Everything up to and including the configuration start tag
+	prolog string
+	nodes  []xmlNode
+	// The configuration end tag and everything after it
+	epilog string
+}
+
+const xmlSectionDescription = "lumio-conf %s"
+
+func xmlSectionOf(p xmlProperty) string {
+	name, found := strings.CutPrefix(p.Description, fmt.Sprintf(xmlSectionDescription, ""))
+	if !found {
+		return ""
+	}
+	return name
+}
+
+func readXmlConfig(filename string) (*xmlConfiguration, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return &xmlConfiguration{prolog: xml.Header + "<configuration>", epilog: "\n</configuration>\n"}, nil
+	}
+	cfg, err := parseXmlConfig(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %s as xml, error is: %s", filename, err.Error())
+	}
+	return cfg, nil
+}
+
+func parseXmlConfig(data string) (*xmlConfiguration, error) {
+	cfg := &xmlConfiguration{}
+	decoder := xml.NewDecoder(strings.NewReader(data))
+	for cfg.prolog == "" {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, isStart := token.(xml.StartElement); isStart {
+			if start.Name.Local != "configuration" {
+				return nil, fmt.Errorf("root element is %s instead of configuration", start.Name.Local)
+			}
+			cfg.prolog = data[:decoder.InputOffset()]
+		}
+	}
+	// <configuration/> has no room for properties
+	if strings.HasSuffix(cfg.prolog, "/>") {
+		cfg.prolog = strings.TrimSuffix(cfg.prolog, "/>") + ">"
+		cfg.epilog = "\n</configuration>"
+	}
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.EndElement:
+			cfg.epilog += data[offset:]
+			return cfg, nil
+		case xml.StartElement:
+			if t.Name.Local == "property" && t.Name.Space == "" {
+				property := &xmlProperty{}
+				err = decoder.DecodeElement(property, &t)
+				if err != nil {
+					return nil, err
+				}
+				cfg.nodes = append(cfg.nodes, xmlNode{raw: data[offset:decoder.InputOffset()], property: property})
+				continue
+			}
+			err = decoder.Skip()
+			if err != nil {
+				return nil, err
+			}
+		}
+		cfg.nodes = append(cfg.nodes, xmlNode{raw: data[offset:decoder.InputOffset()]})
+	}
+}
+
+func isXmlWhitespace(n xmlNode) bool {
+	return n.property == nil && n.raw != "" && strings.TrimSpace(n.raw) == ""
+}
+
+// The whitespace before a property goes with it
+func (cfg *xmlConfiguration) removeProperties(remove func(xmlProperty) bool) bool {
+	removed := false
+	var kept []xmlNode
+	for _, n := range cfg.nodes {
+		if n.property != nil && remove(*n.property) {
+			if len(kept) > 0 && isXmlWhitespace(kept[len(kept)-1]) {
+				kept = kept[:len(kept)-1]
+			}
+			removed = true
+			continue
+		}
+		kept = append(kept, n)
+	}
+	cfg.nodes = kept
+	return removed
+}
+
+// New properties go before the whitespace in front of the end tag
+func (cfg *xmlConfiguration) addProperty(p xmlProperty) {
+	node := xmlNode{property: &p}
+	last := len(cfg.nodes) - 1
+	if last >= 0 && isXmlWhitespace(cfg.nodes[last]) {
+		cfg.nodes = append(cfg.nodes[:last], node, cfg.nodes[last])
+	} else {
+		cfg.nodes = append(cfg.nodes, node)
+	}
+}
+
+func writeXmlConfig(filename string, cfg *xmlConfiguration) error {
+	var content strings.Builder
+	content.WriteString(cfg.prolog)
+	for _, n := range cfg.nodes {
+		if n.raw != "" {
+			content.WriteString(n.raw)
+			continue
+		}
+		data, err := xml.MarshalIndent(n.property, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		content.WriteString("\n")
+		content.Write(data)
+	}
+	content.WriteString(cfg.epilog)
+	return os.WriteFile(filename, []byte(content.String()), 0600)
+}
+
+type XmlFormat struct{}
+
+type xmlConfigFile struct {
+	cfg *xmlConfiguration
+}
+
+func (XmlFormat) Name() string {
+	return "xml"
+}
+
+func (XmlFormat) Read(filename string) (ConfigFile, error) {
+	cfg, err := readXmlConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &xmlConfigFile{cfg: cfg}, nil
+}
+
+func (c *xmlConfigFile) SectionNames() []string {
+	var names []string
+	for _, n := range c.cfg.nodes {
+		if n.property == nil {
+			continue
+		}
+		section := xmlSectionOf(*n.property)
+		if section != "" && !StringInSlice(section, names) {
+			names = append(names, section)
+		}
+	}
+	return names
+}
+
+// Property names are global, a property set for the section
+// replaces the same property in any other section
+func (c *xmlConfigFile) UpsertSection(name string, values map[string]any, replace bool) error {
+	c.cfg.removeProperties(func(p xmlProperty) bool {
+		_, found := values[p.Name]
+		return found || (replace && xmlSectionOf(p) == name)
+	})
+	for _, k := range SortedKeys(values) {
+		c.cfg.addProperty(xmlProperty{Name: k, Value: fmt.Sprint(values[k]), Description: fmt.Sprintf(xmlSectionDescription, name)})
+	}
+	return nil
+}
+
+func (c *xmlConfigFile) DeleteSection(name string) bool {
+	return c.cfg.removeProperties(func(p xmlProperty) bool {
+		return xmlSectionOf(p) == name
+	})
+}
+
+func (c *xmlConfigFile) Write(filename string) error {
+	return writeXmlConfig(filename, c.cfg)
+}
+
+// Xml counterpart of UpdateConfig
+func UpdateXmlConfig(config map[string]map[string]string, oldConfigFilePath string, newConfigFilePath string, carefull bool, singleSectionOnly bool) (string, error) {
+	return UpdateConfigFile(XmlFormat{}, toAnySections(config), oldConfigFilePath, newConfigFilePath, carefull, singleSectionOnly)
+}
+
+func ListXmlSections(filename string) ([]string, error) {
+	return ListSections(XmlFormat{}, filename)
+}
+
+func DeleteXmlSectionsFromFile(filename string, sectionNames []string) error {
+	return DeleteSectionsFromFile(XmlFormat{}, filename, sectionNames)
+}
@@ -0,0 +1,203 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Yaml configs are edited on the node level, so that comments, ordering
+// and content not touched by us are kept as is.
+
+func readYamlFile(filename string) (*yaml.Node, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		err = yaml.Unmarshal(data, doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing %s as yaml, error is: %s", filename, err.Error())
+		}
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s does not contain a yaml mapping", filename)
+	}
+	return doc, nil
+}
+
+func writeYamlFile(filename string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(doc)
+	if err != nil {
+		return err
+	}
+	encoder.Close()
+	return os.WriteFile(filename, buf.Bytes(), 0600)
+}
+
+func yamlMappingGet(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Replaces the value for an existing key, keeping its position and comments
+func yamlMappingSet(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+func yamlValueNode(value any) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	err := node.Encode(value)
+	return node, err
+}
+
+// Mapping holding the sections, created if missing
+func getYamlSections(doc *yaml.Node, parentKey string) (*yaml.Node, error) {
+	root := doc.Content[0]
+	if parentKey == "" {
+		return root, nil
+	}
+	sections := yamlMappingGet(root, parentKey)
+	if sections == nil || (sections.Kind == yaml.ScalarNode && sections.Tag == "!!null") {
+		sections = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		yamlMappingSet(root, parentKey, sections)
+	}
+	if sections.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("value for %s is not a yaml mapping", parentKey)
+	}
+	return sections, nil
+}
+
+type YamlFormat struct {
+	ParentKey string
+}
+
+type yamlConfigFile struct {
+	doc      *yaml.Node
+	sections *yaml.Node
+}
+
+func (YamlFormat) Name() string {
+	return "yaml"
+}
+
+func (f YamlFormat) Read(filename string) (ConfigFile, error) {
+	doc, err := readYamlFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := getYamlSections(doc, f.ParentKey)
+	if err != nil {
+		return nil, err
+	}
+	return &yamlConfigFile{doc: doc, sections: sections}, nil
+}
+
+func (c *yamlConfigFile) SectionNames() []string {
+	var names []string
+	for i := 0; i+1 < len(c.sections.Content); i += 2 {
+		names = append(names, c.sections.Content[i].Value)
+	}
+	return names
+}
+
+func (c *yamlConfigFile) UpsertSection(name string, values map[string]any, replace bool) error {
+	section := yamlMappingGet(c.sections, name)
+	if section == nil || section.Kind != yaml.MappingNode || replace {
+		section = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		yamlMappingSet(c.sections, name, section)
+	}
+	for _, k := range SortedKeys(values) {
+		value, err := yamlValueNode(values[k])
+		if err != nil {
+			return err
+		}
+		yamlMappingSet(section, k, value)
+	}
+	return nil
+}
+
+func (c *yamlConfigFile) DeleteSection(name string) bool {
+	return yamlMappingDelete(c.sections, name)
+}
+
+func (c *yamlConfigFile) Write(filename string) error {
+	return writeYamlFile(filename, c.doc)
+}
+
+// Yaml counterpart of UpdateConfig, sections are mappings under parentKey
+// or at the top level when parentKey is empty
+func UpdateYamlConfig(config map[string]map[string]any, parentKey string, oldConfigFilePath string, newConfigFilePath string, carefull bool, singleSectionOnly bool) (string, error) {
+	return UpdateConfigFile(YamlFormat{ParentKey: parentKey}, config, oldConfigFilePath, newConfigFilePath, carefull, singleSectionOnly)
+}
+
+func ListYamlSections(filename string, parentKey string) ([]string, error) {
+	return ListSections(YamlFormat{ParentKey: parentKey}, filename)
+}
+
+func DeleteYamlSectionsFromFile(filename string, parentKey string, sectionNames []string) error {
+	return DeleteSectionsFromFile(YamlFormat{ParentKey: parentKey}, filename, sectionNames)
+}
+
+func yamlMappingDelete(mapping *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Set top level keys, other keys are kept
+func UpdateYamlKeys(values map[string]any, oldConfigFilePath string, newConfigFilePath string) (string, error) {
+	info, err := prepareTempConfig(oldConfigFilePath, newConfigFilePath)
+	if err != nil {
+		return info, err
+	}
+	doc, err := readYamlFile(newConfigFilePath)
+	if err != nil {
+		return "Failed reading yaml config", err
+	}
+	for _, k := range SortedKeys(values) {
+		value, err := yamlValueNode(values[k])
+		if err != nil {
+			return "Failed encoding yaml value", err
+		}
+		yamlMappingSet(doc.Content[0], k, value)
+	}
+	err = writeYamlFile(newConfigFilePath, doc)
+	if err != nil {
+		return "Failed writing yaml config", err
+	}
+	return "", nil
+}
+
+func SetYamlHeadComment(filename string, comment string) error {
+	doc, err := readYamlFile(filename)
+	if err != nil {
+		return err
+	}
+	doc.HeadComment = comment
+	return writeYamlFile(filename, doc)
+}